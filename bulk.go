@@ -0,0 +1,74 @@
+package hdrezka
+
+import (
+	"context"
+	"sync"
+)
+
+// defaultBulkJobs is the number of concurrent page requests when
+// BulkOption.Jobs is not set.
+const defaultBulkJobs = 4
+
+// BulkOption configures GetVideos and StreamVideos.
+type BulkOption struct {
+	// Jobs is the number of videos fetched at once.
+	Jobs int
+}
+
+// VideoResult is the outcome of fetching one URL of a bulk call.
+type VideoResult struct {
+	// Index is the position of URL in the input.
+	Index int
+	URL   string
+	Video *Video
+	Err   error
+}
+
+// GetVideos fetches the videos at urls concurrently and returns one result
+// per URL in input order. A failed URL only sets the Err of its result.
+// Combine with WithRateLimit to keep the request rate polite.
+func (r *HDRezka) GetVideos(ctx context.Context, urls []string, opts BulkOption) []VideoResult {
+	results := make([]VideoResult, len(urls))
+	for result := range r.StreamVideos(ctx, urls, opts) {
+		results[result.Index] = result
+	}
+	return results
+}
+
+// StreamVideos is like GetVideos but delivers each result as soon as it is
+// ready, in completion order. The channel is closed after the last URL; it
+// must be drained. Once ctx is done the remaining URLs are not requested and
+// their results carry ctx.Err().
+func (r *HDRezka) StreamVideos(ctx context.Context, urls []string, opts BulkOption) <-chan VideoResult {
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = defaultBulkJobs
+	}
+	jobs = max(min(jobs, len(urls)), 1)
+
+	indexes := make(chan int)
+	results := make(chan VideoResult, jobs)
+	var wg sync.WaitGroup
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				result := VideoResult{Index: i, URL: urls[i]}
+				if result.Err = ctx.Err(); result.Err == nil {
+					result.Video, result.Err = r.GetVideoContext(ctx, urls[i])
+				}
+				results <- result
+			}
+		}()
+	}
+	go func() {
+		for i := range urls {
+			indexes <- i
+		}
+		close(indexes)
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
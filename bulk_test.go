@@ -0,0 +1,81 @@
+package hdrezka
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newPeakServer starts a test server running handler and records the most
+// requests it handled at once in peak.
+func newPeakServer(t *testing.T, handler http.HandlerFunc) (srv *httptest.Server, peak *atomic.Int32) {
+	var running atomic.Int32
+	peak = new(atomic.Int32)
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		handler(w, req)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, peak
+}
+
+func TestGetVideos(t *testing.T) {
+	t.Parallel()
+
+	srv, peak := newPeakServer(t, func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+
+		id, _, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/films/drama/"), "-")
+		if id == "404" {
+			http.NotFound(w, req)
+			return
+		}
+		fmt.Fprintf(w, `<html><body><h1 itemprop="name">Фильм %s</h1><div class="b-userset__fav_holder" data-post_id="%s"></div></body></html>`, id, id)
+	})
+
+	r := New()
+	r.URL, _ = url.Parse(srv.URL)
+
+	var urls []string
+	for _, id := range []string{"1", "2", "404", "4", "5", "6"} {
+		urls = append(urls, srv.URL+"/films/drama/"+id+"-film.html")
+	}
+	results := r.GetVideos(context.Background(), urls, BulkOption{Jobs: 2})
+	if len(results) != len(urls) {
+		t.Fatalf("GetVideos() returned %d results, want %d", len(results), len(urls))
+	}
+	for i, result := range results {
+		if result.Index != i || result.URL != urls[i] {
+			t.Errorf("result %d = index %d %s, want input order", i, result.Index, result.URL)
+		}
+		if i == 2 {
+			if result.Err == nil {
+				t.Errorf("result %d has no error for a missing page", i)
+			}
+			continue
+		}
+		if result.Err != nil || result.Video == nil || !strings.HasPrefix(result.URL, srv.URL+"/films/drama/"+result.Video.ID+"-") {
+			t.Errorf("result %d = %+v, want the video of %s", i, result, result.URL)
+		}
+	}
+	if p := peak.Load(); p > 2 {
+		t.Errorf("GetVideos() ran %d requests at once, want at most 2", p)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for result := range r.StreamVideos(ctx, urls, BulkOption{}) {
+		if result.Err != context.Canceled {
+			t.Errorf("StreamVideos() with cancelled context = %v, want context.Canceled", result.Err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package hdrezka
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// topnavItems maps each genre to its drop-down in the site top navigation.
+var topnavItems = map[Genre]string{
+	Films:    "li.b-topnav__item.i1",
+	Series:   "li.b-topnav__item.i2",
+	Cartoons: "li.b-topnav__item.i3",
+	Show:     "li.b-topnav__item.i4",
+	Anime:    "li.b-topnav__item.i5",
+}
+
+// genreIDs are the numeric genre identifiers the site expects in "genre"
+// query parameters and AJAX forms.
+var genreIDs = map[Genre]string{
+	Films:    "1",
+	Series:   "2",
+	Cartoons: "3",
+	Show:     "4",
+	Anime:    "82",
+}
+
+// discoverCatalog fills Categories, Countries, Filters and Years from the
+// home page navigation.
+func (r *HDRezka) discoverCatalog(doc *goquery.Document) {
+	for genre, item := range topnavItems {
+		r.Categories[genre] = getCategory(item+" > div > div > ul.left > li", doc)
+	}
+	if len(r.Categories[Show]) == 0 {
+		r.Categories[Show] = categoriesShow
+	}
+
+	r.Countries = make(map[string]string)
+	doc.Find("a[href*='/country/']").Each(func(i int, s *goquery.Selection) {
+		u, err := url.Parse(s.AttrOr("href", ""))
+		if err != nil {
+			return
+		}
+		slug := path.Base(strings.TrimSuffix(u.Path, "/"))
+		if slug == "" || slug == "country" || slug == "." || slug == "/" {
+			return
+		}
+		name := strings.TrimSpace(s.Text())
+		if name == "" {
+			name = slug
+		}
+		r.Countries[name] = slug
+	})
+
+	r.Filters = make(map[Filter]string)
+	doc.Find(".b-content__main_filters a").Each(func(i int, s *goquery.Selection) {
+		filter := s.AttrOr("data-filter", "")
+		if filter == "" {
+			if u, err := url.Parse(s.AttrOr("href", "")); err == nil {
+				filter = u.Query().Get("filter")
+			}
+		}
+		if filter != "" {
+			r.Filters[Filter(filter)] = strings.TrimSpace(s.Text())
+		}
+	})
+
+	r.Years = r.Years[:0]
+	doc.Find("#find-best-block-1 > div > select.select-year > option").Each(func(i int, s *goquery.Selection) {
+		if s.Text() == "за все время" {
+			return
+		}
+		r.Years = append(r.Years, s.Text())
+	})
+}
+
+// checkCoverOption validates opts against the discovered catalog. Countries,
+// filters and years are only checked when the site exposed a list of them.
+func (r *HDRezka) checkCoverOption(opts CoverOption) error {
+	if opts.Genre != All && !slices.Contains(genres, opts.Genre) {
+		return fmt.Errorf("unknown genre %q, available: %s", opts.Genre, joinGenres(genres))
+	}
+	if opts.Year != "" && (len(opts.Year) != 4 || parseInt(opts.Year) == 0) {
+		return fmt.Errorf("invalid year %q, expected four digits", opts.Year)
+	}
+	if opts.Year != "" && len(r.Years) > 0 && !slices.Contains(r.Years, opts.Year) {
+		return fmt.Errorf("unknown year %q, available: %s-%s", opts.Year, slices.Min(r.Years), slices.Max(r.Years))
+	}
+	if opts.Type == CoverByYear && opts.Year == "" {
+		return fmt.Errorf("year is required for releases by year")
+	}
+	if opts.Filter != "" && len(r.Filters) > 0 {
+		if _, found := r.Filters[opts.Filter]; !found {
+			return fmt.Errorf("unknown filter %q, available: %s", opts.Filter, joinKeys(r.Filters))
+		}
+	}
+	if opts.Type == CoverByCountry {
+		if opts.Country == "" {
+			return fmt.Errorf("country is required for releases by country")
+		}
+		if _, err := r.countrySlug(opts.Country); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countrySlug resolves a country name to the path segment used by
+// "/country/<slug>/" listings. Without a discovered country list the name
+// is used as is.
+func (r *HDRezka) countrySlug(country string) (string, error) {
+	if len(r.Countries) == 0 {
+		return country, nil
+	}
+	if slug, found := r.Countries[country]; found {
+		return slug, nil
+	}
+	for name, slug := range r.Countries {
+		if strings.EqualFold(name, country) || strings.EqualFold(slug, country) {
+			return slug, nil
+		}
+	}
+	return "", fmt.Errorf("country %q not found, available: %s", country, joinKeys(r.Countries))
+}
+
+func joinKeys[K ~string, V any](m map[K]V) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, string(k))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
+
+func joinGenres(list []Genre) string {
+	names := make([]string, 0, len(list))
+	for _, genre := range list {
+		names = append(names, string(genre))
+	}
+	return strings.Join(names, ", ")
+}
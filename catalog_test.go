@@ -0,0 +1,96 @@
+package hdrezka
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const homeFixture = `<html><body>
+<ul class="b-topnav__menu">
+	<li class="b-topnav__item i1"><div><div><ul class="left">
+		<li><a href="/films/drama/">Драмы</a></li>
+		<li><a href="/films/comedy/">Комедии</a></li>
+	</ul></div></div></li>
+	<li class="b-topnav__item i4"><div><div><ul class="left">
+		<li><a href="/show/sport/">Спортивные</a></li>
+	</ul></div></div></li>
+</ul>
+<div class="b-content__main_filters">
+	<a class="b-content__main_filters_link" data-filter="last" href="/?filter=last">Последние поступления</a>
+	<a class="b-content__main_filters_link" href="/?filter=popular">Популярные</a>
+</div>
+<div id="find-best-block-1"><div><select class="select-year">
+	<option>за все время</option>
+	<option>2024</option>
+	<option>2023</option>
+</select></div></div>
+<a href="/country/%D0%A1%D0%A8%D0%90/">США</a>
+<a href="/country/Франция/">Франция</a>
+</body></html>`
+
+func TestDiscoverCatalog(t *testing.T) {
+	t.Parallel()
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(homeFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	r.URL, _ = url.Parse("https://hdrezka.ag/")
+	r.discoverCatalog(doc)
+
+	if got := r.Categories[Films]["Комедии"]; got != "/films/comedy/" {
+		t.Errorf("Categories[films][Комедии] = %q, want /films/comedy/", got)
+	}
+	if got := r.Categories[Show]["Спортивные"]; got != "/show/sport/" {
+		t.Errorf("Categories[show][Спортивные] = %q, want /show/sport/", got)
+	}
+	if len(r.Categories[Show]) != 1 {
+		t.Errorf("Categories[show] = %v, want the discovered menu only", r.Categories[Show])
+	}
+	if got := r.Countries["США"]; got != "США" {
+		t.Errorf("Countries[США] = %q, want США", got)
+	}
+	if _, ok := r.Filters[FilterPopular]; !ok {
+		t.Errorf("Filters = %v, want %q discovered", r.Filters, FilterPopular)
+	}
+	if got := strings.Join(r.Years, ","); got != "2024,2023" {
+		t.Errorf("Years = %q, want 2024,2023", got)
+	}
+
+	tests := []struct {
+		name    string
+		opts    CoverOption
+		want    string
+		wantErr string
+	}{
+		{"known country", CoverOption{Type: CoverByCountry, Country: "Франция", Genre: Films},
+			"https://hdrezka.ag/country/%D0%A4%D1%80%D0%B0%D0%BD%D1%86%D0%B8%D1%8F/?genre=1", ""},
+		{"unknown country", CoverOption{Type: CoverByCountry, Country: "Атлантида"}, "", "available: США, Франция"},
+		{"unknown filter", CoverOption{Filter: "soon"}, "", "unknown filter"},
+		{"unknown genre", CoverOption{Genre: "music"}, "", "unknown genre"},
+		{"invalid year", CoverOption{Type: CoverByYear, Year: "86"}, "", "invalid year"},
+		{"known year", CoverOption{Type: CoverByYear, Year: "2023"}, "https://hdrezka.ag/year/2023/", ""},
+		{"unknown year", CoverOption{Type: CoverByYear, Year: "1986"}, "", "available: 2023-2024"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.GetCoversURL(tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GetCoversURL() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetCoversURL() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GetCoversURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"net/http"
 	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/n0madic/go-hdrezka"
+	"github.com/n0madic/go-hdrezka/nfo"
+	"github.com/n0madic/go-hdrezka/subtitles"
 	"github.com/schollz/progressbar/v3"
 )
 
@@ -16,59 +25,298 @@ import (
 // cookie jar carries the authenticated session through every request.
 var siteClient *http.Client
 
-func downloadHLSPlaylist(playlistURL, output string) error {
-	fileInfo, err := os.Stat(output)
-	if err == nil && fileInfo.Size() > 0 {
-		if !args.Overwrite {
-			return nil // File already exists
-		}
-	}
+// refreshFunc requests fresh links for a download whose signed links
+// expired midway.
+type refreshFunc func(ctx context.Context) ([]string, error)
 
-	bar := progressbar.NewOptions(
-		-1, // Unknown size initially
-		progressbar.OptionSetDescription("downloading HLS "+output),
-		progressbar.OptionSetWriter(os.Stderr),
-		progressbar.OptionShowCount(),
-		progressbar.OptionOnCompletion(func() {
-			fmt.Fprint(os.Stderr, "\n")
-		}),
-		progressbar.OptionFullWidth(),
-		progressbar.OptionSetRenderBlankState(true),
-	)
+// isLinkExpired reports whether err is the CDN rejecting an expired link.
+func isLinkExpired(err error) bool {
+	var statusErr *hdrezka.StatusError
+	return errors.As(err, &statusErr) &&
+		(statusErr.StatusCode == http.StatusForbidden || statusErr.StatusCode == http.StatusGone)
+}
 
+func downloadHLSPlaylist(playlistURLs []string, refresh refreshFunc, output string) error {
 	downloader := NewHLSDownloader(siteClient)
+	downloader.SetJobs(args.HLSJobs)
+	downloader.SetOverwrite(args.Overwrite)
+	downloader.SetVariantFilter(VariantFilter{
+		MaxHeight:    args.HLSResolution,
+		MaxBandwidth: args.HLSMaxBandwidth,
+		Codec:        args.HLSCodec,
+	})
+	downloader.SetRefreshFunc(refresh)
+
+	// One bar per file, shown once its first segment is written
+	var (
+		bar     *progressbar.ProgressBar
+		current string
+	)
 	downloader.SetProgressCallback(func(info HLSProgressInfo) {
+		if bar == nil || info.Output != current {
+			current = info.Output
+			bar = progressbar.NewOptions(
+				-1, // Unknown size initially
+				progressbar.OptionSetDescription("downloading HLS "+info.Output),
+				progressbar.OptionSetWriter(os.Stderr),
+				progressbar.OptionShowCount(),
+				progressbar.OptionOnCompletion(func() {
+					fmt.Fprint(os.Stderr, "\n")
+				}),
+				progressbar.OptionFullWidth(),
+			)
+		}
 		bar.Set(info.CurrentSegment)
 		if info.TotalSegments > 0 {
 			bar.ChangeMax(info.TotalSegments)
 		}
 	})
 
-	err = downloader.Download(playlistURL, output)
-	if err != nil {
+	if err := downloader.Download(playlistURLs, output); err != nil {
 		return fmt.Errorf("error downloading HLS: %w", err)
 	}
 
+	if args.HLSAudio != "" {
+		saved, err := downloader.DownloadAudio(playlistURLs, strings.Split(args.HLSAudio, ","), output)
+		if err != nil {
+			return fmt.Errorf("error downloading HLS audio: %w", err)
+		}
+		if len(saved) == 0 {
+			fmt.Printf("No separate audio rendition matches %s\n", args.HLSAudio)
+		}
+	}
+
+	return nil
+}
+
+// downloadTrailer saves the video trailer next to output as
+// "<name>-trailer<ext>", the name media servers pick up as a local trailer.
+// Trailers that only exist as an embedded player page are reported instead.
+func downloadTrailer(video *hdrezka.Video, output string) {
+	trailer, err := video.GetTrailer()
+	if err != nil {
+		fmt.Printf("ERROR trailer: %s\n", err)
+		return
+	}
+	if trailer.URL == "" {
+		fmt.Printf("Trailer is only available as an embed: %s\n", trailer.EmbedURL)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+		fmt.Printf("ERROR trailer: %s\n", err)
+		return
+	}
+	base := strings.TrimSuffix(output, filepath.Ext(output)) + "-trailer"
+	if strings.Contains(trailer.URL, ".m3u8") {
+		err = downloadHLSPlaylist([]string{trailer.URL}, nil, base+".ts")
+	} else {
+		ext := path.Ext(strings.SplitN(trailer.URL, "?", 2)[0])
+		if ext == "" {
+			ext = ".mp4"
+		}
+		err = downloadFile([]string{trailer.URL}, nil, base+ext, minMediaSize, args.MaxAttempt)
+	}
+	if err != nil {
+		fmt.Printf("ERROR trailer: %s\n", err)
+	}
+}
+
+// writeVideoNFO saves the NFO of the video with the cover as its poster.
+// Series and movies in their own directory get tvshow.nfo or movie.nfo and
+// poster.jpg in dir; movies sharing a directory get "<name>.nfo" and
+// "<name>-poster.jpg" next to output instead.
+func writeVideoNFO(video *hdrezka.Video, series, ownDir bool, dir, output string) {
+	name, doc := filepath.Join(dir, "movie.nfo"), any(nfo.NewMovie(video))
+	poster := filepath.Join(dir, "poster.jpg")
+	switch {
+	case series:
+		name, doc = filepath.Join(dir, "tvshow.nfo"), nfo.NewTVShow(video)
+	case !ownDir:
+		base := strings.TrimSuffix(output, filepath.Ext(output))
+		name, poster = base+".nfo", base+"-poster.jpg"
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		fmt.Printf("ERROR %s: %s\n", name, err)
+		return
+	}
+	if err := writeNFO(name, doc); err != nil {
+		fmt.Printf("ERROR %s: %s\n", name, err)
+	}
+	if video.Cover != "" {
+		if err := replaceFile([]string{video.Cover}, poster, minImageSize); err != nil {
+			fmt.Printf("ERROR %s: %s\n", poster, err)
+		}
+	}
+}
+
+// replaceFile downloads urls to a temporary file next to output and moves
+// it into place, so an existing file is replaced instead of resumed.
+func replaceFile(urls []string, output string, minSize int64) error {
+	tmp, err := os.CreateTemp(filepath.Dir(output), filepath.Base(output)+".*")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	if err := downloadFile(urls, nil, tmp.Name(), minSize, args.MaxAttempt); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), output)
+}
+
+// writeNFO saves an NFO document of the nfo package to output.
+func writeNFO(output string, doc any) error {
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := nfo.Write(file, doc); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// downloadSubtitles saves the --subtitle track, or every track for "all",
+// next to output in the --subtitle-format format. WebVTT is saved as served;
+// other formats are converted.
+func downloadSubtitles(stream *hdrezka.Stream, output string) error {
+	format, err := subtitles.ParseFormat(args.SubFormat)
+	if err != nil {
+		return err
+	}
+	base := strings.TrimSuffix(output, filepath.Ext(output))
+
+	var tracks []hdrezka.SubtitleTrack
+	for _, track := range stream.SubtitleTracks {
+		if args.Subtitle == "all" || track.Label == args.Subtitle || string(track.Language) == args.Subtitle {
+			tracks = append(tracks, track)
+		}
+	}
+	if len(tracks) == 0 {
+		return fmt.Errorf("subtitle %s not found", args.Subtitle)
+	}
+	if args.Subtitle != "all" {
+		tracks = tracks[:1]
+	}
+
+	// With several tracks, name files "<base>.<lang>.<ext>" so media servers
+	// pick the language up; fall back to the label for unknown or repeated
+	// languages.
+	used := make(map[string]bool)
+	for _, track := range tracks {
+		outputSub := base + "." + string(format)
+		if len(tracks) > 1 {
+			suffix := string(track.Language)
+			if suffix == "" || used[suffix] {
+				suffix = sanitizeFilename(track.Label)
+			}
+			used[suffix] = true
+			outputSub = base + "." + suffix + "." + string(format)
+		}
+
+		urls := track.URLs
+		if len(urls) == 0 {
+			urls = []string{track.URL}
+		}
+		if format == subtitles.VTT {
+			err = downloadFile(urls, nil, outputSub, minSubtitleSize, args.MaxAttempt)
+		} else {
+			err = convertSubtitle(urls, outputSub, format)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", outputSub, err)
+		}
+	}
 	return nil
 }
 
-func downloadFile(url, output string, maxAttempt int) error {
-	for attempt := 1; attempt <= maxAttempt; attempt++ {
-		err := attemptDownload(url, output)
+func convertSubtitle(urls []string, output string, format subtitles.Format) error {
+	resp, err := hdrezka.OpenAlternatives(siteClient, urls, nil, minSubtitleSize)
+	if err != nil {
+		return err
+	}
+	track, err := subtitles.ParseVTT(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := track.Write(file, format); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Smallest bodies accepted from a CDN node; anything shorter is an error
+// page and the next node is tried.
+const (
+	minImageSize    = 1 << 10
+	minMediaSize    = 64 << 10
+	minSubtitleSize = int64(len("WEBVTT"))
+)
+
+// downloadFile saves the first working alternative of urls to output,
+// retrying up to maxAttempt times. Bodies shorter than minSize are treated
+// as broken nodes. When the links expire and refresh is set, the download
+// resumes from fresh links; a refresh does not use up an attempt. With
+// --overwrite an existing file is truncated first.
+func downloadFile(urls []string, refresh refreshFunc, output string, minSize int64, maxAttempt int) error {
+	if args.Overwrite {
+		// Start over instead of resuming
+		if err := os.Truncate(output, 0); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	refreshed := false
+	for attempt := 1; ; {
+		before := fileSize(output)
+		err := attemptDownload(urls, output, minSize)
 		if err == nil {
 			return nil
 		}
-		if attempt == maxAttempt {
+		// Links that expire again after more data came in are a new expiry
+		if fileSize(output) > before {
+			refreshed = false
+		}
+		if refresh != nil && isLinkExpired(err) && !refreshed {
+			refreshed = true
+			fresh, refreshErr := refresh(context.Background())
+			if refreshErr == nil {
+				fmt.Printf("Stream links expired, resuming %s with fresh links\n", output)
+				urls = fresh
+				continue
+			}
+			fmt.Printf("Error refreshing stream links: %v\n", refreshErr)
+		}
+		if attempt >= maxAttempt {
 			return fmt.Errorf("after %d attempts, last error: %v", maxAttempt, err)
 		}
 		waitTime := time.Duration(attempt*attempt)*time.Second + time.Duration(rand.Intn(1000))*time.Millisecond
-		fmt.Printf("Error downloading file: %v\nRetrying in %v, attempt %d\n", err, waitTime, attempt+1)
+		attempt++
+		fmt.Printf("Error downloading file: %v\nRetrying in %v, attempt %d\n", err, waitTime, attempt)
 		time.Sleep(waitTime)
 	}
-	return nil // This line will never be reached, but it's needed for compilation
 }
 
-func attemptDownload(url, output string) error {
+// fileSize returns the size of path, or zero when it cannot be read.
+func fileSize(path string) int64 {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return fileInfo.Size()
+}
+
+// attemptDownload fetches output from the first working CDN node in urls,
+// resuming a partial file when the node supports ranges and its
+// Content-Range agrees with the local size.
+func attemptDownload(urls []string, output string, minSize int64) error {
 	file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
 		return fmt.Errorf("error opening file: %w", err)
@@ -81,54 +329,49 @@ func attemptDownload(url, output string) error {
 	}
 	currentSize := fileInfo.Size()
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
-	}
-
-	headResp, err := siteClient.Head(url)
-	if err != nil {
-		return fmt.Errorf("error making HEAD request: %w", err)
-	}
-	headResp.Body.Close()
-
-	totalSize := headResp.ContentLength
+	var resp *http.Response
+	totalSize := int64(-1)
 	if currentSize > 0 {
-		if totalSize > 0 && totalSize == currentSize {
-			return nil // File already completely downloaded
+		header := http.Header{"Range": {fmt.Sprintf("bytes=%d-", currentSize)}}
+		if resp, err = hdrezka.OpenAlternatives(siteClient, urls, header, minSize); err != nil {
+			return err
 		}
-		if headResp.Header.Get("Accept-Ranges") == "bytes" && totalSize > currentSize {
-			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", currentSize))
-		} else {
-			if err := file.Truncate(0); err != nil {
-				return fmt.Errorf("error truncating file: %w", err)
-			}
-			if _, err := file.Seek(0, 0); err != nil {
-				return fmt.Errorf("error seeking file: %w", err)
-			}
+		start, total := parseContentRange(resp.Header.Get("Content-Range"))
+		switch {
+		case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && total == currentSize:
+			resp.Body.Close()
+			return nil // File already completely downloaded
+		case resp.StatusCode == http.StatusPartialContent && start == currentSize && total > currentSize:
+			totalSize = total
+		case resp.StatusCode == http.StatusOK:
+			// The node ignored the range, start over
 			currentSize = 0
+		default:
+			// The partial file does not match the remote one, start over
+			resp.Body.Close()
+			resp, currentSize = nil, 0
 		}
 	}
-
-	resp, err := siteClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("error making GET request: %w", err)
+	if currentSize == 0 {
+		if err := file.Truncate(0); err != nil {
+			return fmt.Errorf("error truncating file: %w", err)
+		}
+	}
+	if resp == nil {
+		if resp, err = hdrezka.OpenAlternatives(siteClient, urls, nil, minSize); err != nil {
+			return err
+		}
 	}
 	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-		return fmt.Errorf("HTTP request returned status: %s", resp.Status)
+	if _, err := file.Seek(currentSize, io.SeekStart); err != nil {
+		return fmt.Errorf("error seeking file: %w", err)
 	}
 
-	// Fallback to GET Content-Length if HEAD returned 0 (CDN servers often ignore HEAD)
-	if totalSize <= 0 {
+	if totalSize < 0 && resp.ContentLength > 0 {
 		totalSize = resp.ContentLength
 	}
-	if totalSize <= 0 {
-		totalSize = -1 // Unknown size — progressbar spinner mode
-	}
 
-	bar := progressbar.DefaultBytes(totalSize, "downloading "+output)
+	bar := progressbar.DefaultBytes(totalSize, "downloading "+output) // -1 is spinner mode
 	if currentSize > 0 {
 		bar.Add64(currentSize)
 	}
@@ -140,3 +383,23 @@ func attemptDownload(url, output string) error {
 
 	return file.Sync()
 }
+
+// parseContentRange returns the first byte and the complete length of a
+// Content-Range header such as "bytes 100-199/200" or "bytes */200", or -1
+// for the parts it does not state.
+func parseContentRange(value string) (start, total int64) {
+	start, total = -1, -1
+	rng, size, found := strings.Cut(strings.TrimPrefix(value, "bytes "), "/")
+	if !found {
+		return start, total
+	}
+	if n, err := strconv.ParseInt(size, 10, 64); err == nil {
+		total = n
+	}
+	if first, _, found := strings.Cut(rng, "-"); found {
+		if n, err := strconv.ParseInt(first, 10, 64); err == nil {
+			start = n
+		}
+	}
+	return start, total
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	siteClient = &http.Client{}
+	args.MaxAttempt = 1
+	os.Exit(m.Run())
+}
+
+func TestParseContentRange(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value     string
+		wantStart int64
+		wantTotal int64
+	}{
+		{"bytes 100-199/200", 100, 200},
+		{"bytes */200", -1, 200},
+		{"bytes 0-99/*", 0, -1},
+		{"", -1, -1},
+		{"garbage", -1, -1},
+	}
+	for _, tt := range tests {
+		start, total := parseContentRange(tt.value)
+		if start != tt.wantStart || total != tt.wantTotal {
+			t.Errorf("parseContentRange(%q) = %d, %d, want %d, %d", tt.value, start, total, tt.wantStart, tt.wantTotal)
+		}
+	}
+}
+
+func TestAttemptDownload(t *testing.T) {
+	t.Parallel()
+
+	body := strings.Repeat("0123456789", 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/norange" {
+			io.WriteString(w, body)
+			return
+		}
+		http.ServeContent(w, req, "video.mp4", time.Time{}, strings.NewReader(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name     string
+		path     string
+		existing string
+	}{
+		{"new", "/video.mp4", ""},
+		{"resume", "/video.mp4", body[:50]},
+		{"complete", "/video.mp4", body},
+		{"larger stale file", "/video.mp4", body + strings.Repeat("x", 50)},
+		{"range ignored", "/norange", strings.Repeat("x", 50)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			output := filepath.Join(t.TempDir(), "video.mp4")
+			if tt.existing != "" {
+				if err := os.WriteFile(output, []byte(tt.existing), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := attemptDownload([]string{srv.URL + tt.path}, output, 1); err != nil {
+				t.Fatalf("attemptDownload() error = %v", err)
+			}
+			got, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, []byte(body)) {
+				t.Errorf("attemptDownload() wrote %q, want %q", got, body)
+			}
+		})
+	}
+}
+
+func TestReplaceFile(t *testing.T) {
+	t.Parallel()
+
+	poster := strings.Repeat("new", 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.ServeContent(w, req, "poster.jpg", time.Time{}, strings.NewReader(poster))
+	}))
+	t.Cleanup(srv.Close)
+
+	// Both a shorter and a longer file of another video must be replaced,
+	// not resumed
+	for _, existing := range []string{"old", strings.Repeat("old", 20)} {
+		output := filepath.Join(t.TempDir(), "poster.jpg")
+		if err := os.WriteFile(output, []byte(existing), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := replaceFile([]string{srv.URL}, output, 1); err != nil {
+			t.Fatalf("replaceFile() error = %v", err)
+		}
+		if got, _ := os.ReadFile(output); string(got) != poster {
+			t.Errorf("replaceFile() over %q wrote %q, want %q", existing, got, poster)
+		}
+		if entries, _ := os.ReadDir(filepath.Dir(output)); len(entries) != 1 {
+			t.Errorf("replaceFile() left %d files, want 1", len(entries))
+		}
+	}
+}
+
+func TestDownloadFileRefresh(t *testing.T) {
+	t.Parallel()
+
+	body := strings.Repeat("x", 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/expired" {
+			http.Error(w, "expired", http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+
+	// A single attempt is enough when the refresh brings working links
+	output := filepath.Join(t.TempDir(), "video.mp4")
+	refreshes := 0
+	refresh := func(ctx context.Context) ([]string, error) {
+		refreshes++
+		return []string{srv.URL + "/fresh"}, nil
+	}
+	if err := downloadFile([]string{srv.URL + "/expired"}, refresh, output, 1, 1); err != nil {
+		t.Fatalf("downloadFile() error = %v", err)
+	}
+	if got, _ := os.ReadFile(output); string(got) != body || refreshes != 1 {
+		t.Errorf("downloadFile() wrote %d bytes after %d refreshes, want %d after 1", len(got), refreshes, len(body))
+	}
+
+	// Links still expired after a refresh are not refreshed forever
+	refreshes = 0
+	stale := func(ctx context.Context) ([]string, error) {
+		refreshes++
+		return []string{srv.URL + "/expired"}, nil
+	}
+	if err := downloadFile([]string{srv.URL + "/expired"}, stale, output+".2", 1, 1); err == nil || refreshes != 1 {
+		t.Errorf("downloadFile() with stale refreshes error = %v after %d refreshes, want an error after 1", err, refreshes)
+	}
+}
@@ -2,31 +2,40 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/grafov/m3u8"
+	"github.com/n0madic/go-hdrezka"
 )
 
 // HLSDownloader represents an HLS playlist downloader
 type HLSDownloader struct {
 	Headers          http.Header   // Custom HTTP headers
+	Variant          VariantFilter // Variant picked from master playlists
+	Jobs             int           // Number of segments downloaded at once
 	RetryAttempts    int           // Number of retry attempts for failed downloads
 	RetryDelay       time.Duration // Delay between retry attempts
+	Overwrite        bool          // Start over instead of resuming or skipping complete files
 	client           *http.Client
 	progressCallback HLSProgressCallback // Progress reporting function
+	refresh          refreshFunc         // Fresh playlist URLs after links expire
 }
 
 // HLSProgressInfo contains information about download progress
 type HLSProgressInfo struct {
-	TotalSegments   int   // Total number of segments
-	DownloadedBytes int64 // Downloaded bytes
-	CurrentSegment  int   // Current downloading segment
+	Output          string // File being downloaded
+	TotalSegments   int    // Total number of segments
+	DownloadedBytes int64  // Downloaded bytes
+	CurrentSegment  int    // Current downloading segment
 }
 
 // HLSProgressCallback defines the interface for progress callback functions
@@ -41,6 +50,7 @@ func NewHLSDownloader(client *http.Client) *HLSDownloader {
 	}
 	return &HLSDownloader{
 		Headers:       make(http.Header),
+		Jobs:          1,           // Default to sequential downloads
 		RetryAttempts: 3,           // Default to 3 retry attempts
 		RetryDelay:    time.Second, // Default to 1 second delay between retries
 		client:        client,
@@ -55,6 +65,20 @@ func (d *HLSDownloader) SetRetryAttempts(attempts int) {
 	d.RetryAttempts = attempts
 }
 
+// SetJobs sets the number of segments downloaded at once
+func (d *HLSDownloader) SetJobs(jobs int) {
+	if jobs < 1 {
+		jobs = 1
+	}
+	d.Jobs = jobs
+}
+
+// SetOverwrite sets whether downloads start over instead of resuming
+// partial files and skipping complete ones
+func (d *HLSDownloader) SetOverwrite(overwrite bool) {
+	d.Overwrite = overwrite
+}
+
 // SetRetryDelay sets the delay between retry attempts
 func (d *HLSDownloader) SetRetryDelay(delay time.Duration) {
 	if delay < 0 {
@@ -68,158 +92,319 @@ func (d *HLSDownloader) SetProgressCallback(callback HLSProgressCallback) {
 	d.progressCallback = callback
 }
 
-// Download downloads an HLS playlist from the specified URL and saves it to a single TS file
-func (d *HLSDownloader) Download(playlistURL, outputPath string) error {
-	// Create output file
-	outFile, err := os.Create(outputPath)
+// SetRefreshFunc sets the function that supplies fresh playlist URLs when
+// the CDN rejects expired links; the download continues from the segment
+// that failed.
+func (d *HLSDownloader) SetRefreshFunc(refresh refreshFunc) {
+	d.refresh = refresh
+}
+
+// SetVariantFilter sets how a variant is picked from master playlists
+func (d *HLSDownloader) SetVariantFilter(filter VariantFilter) {
+	d.Variant = filter
+}
+
+// playlistResolver fetches the media playlist to download from the first
+// working URL of playlistURLs and returns it with the URL it came from.
+type playlistResolver func(playlistURLs []string) (*m3u8.MediaPlaylist, *url.URL, error)
+
+// Download downloads an HLS playlist from the first working URL of
+// playlistURLs, the CDN alternatives of one stream, and saves it to a single
+// TS file. Progress is kept in a sidecar file next to it: a partial download
+// of the same playlist resumes after its last written segment, and the
+// sidecar is marked complete at the end. A complete file is skipped unless
+// Overwrite is set.
+func (d *HLSDownloader) Download(playlistURLs []string, outputPath string) error {
+	return d.download(playlistURLs, outputPath, d.fetchMediaPlaylist)
+}
+
+// download saves the media playlist resolve picks from playlistURLs to
+// outputPath. resolve is called again when links expire.
+func (d *HLSDownloader) download(playlistURLs []string, outputPath string, resolve playlistResolver) error {
+	if d.Overwrite {
+		// Start over instead of resuming
+		os.Remove(outputPath + hlsStateSuffix)
+	} else if hlsComplete(outputPath) {
+		return nil
+	}
+
+	mediapl, baseURL, err := resolve(playlistURLs)
+	if err != nil {
+		return fmt.Errorf("failed to download playlist: %w", err)
+	}
+	// Refuse encryption we cannot undo before writing ciphertext
+	if err := checkEncryption(mediapl); err != nil {
+		return err
+	}
+	segments := 0
+	for _, segment := range mediapl.Segments {
+		if segment != nil {
+			segments++
+		}
+	}
+
+	outFile, err := os.OpenFile(outputPath, os.O_RDWR|os.O_CREATE, 0o644)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
 	}
 	defer outFile.Close()
 
+	// Resume only a partial download of the same playlist whose file still
+	// holds every byte the sidecar recorded
+	state := loadHLSState(outputPath)
+	if fileInfo, err := outFile.Stat(); state == nil || state.Complete || state.Segments != segments ||
+		!samePlaylist(state.PlaylistURL, baseURL) || err != nil || fileInfo.Size() < state.Offset {
+		state = &hlsState{Segments: segments}
+	}
+	state.PlaylistURL = baseURL.String()
+	// Drop whatever was written after the last recorded segment
+	if err := outFile.Truncate(state.Offset); err != nil {
+		return fmt.Errorf("failed to truncate output file: %w", err)
+	}
+	if _, err := outFile.Seek(state.Offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek output file: %w", err)
+	}
+	if err := state.save(outputPath); err != nil {
+		return fmt.Errorf("failed to save download state: %w", err)
+	}
+
 	// Download segments to the file
-	err = d.downloadPlaylist(playlistURL, outFile)
-	if err != nil {
+	source := &segmentSource{d: d, keys: hlsKeys{d: d}, resolve: resolve, playlist: mediapl, baseURL: baseURL}
+	if err := d.downloadSegments(source, outFile, outputPath, state); err != nil {
 		return fmt.Errorf("failed to download playlist: %w", err)
 	}
 
 	return nil
 }
 
-// downloadPlaylist handles the playlist download process
-func (d *HLSDownloader) downloadPlaylist(playlistURL string, out io.Writer) error {
-	// Fetch the playlist
-	resp, err := d.makeRequest(playlistURL)
+// samePlaylist reports whether saved, a playlist URL from a sidecar, names
+// the same playlist as u. CDN nodes and signed queries change between runs,
+// so only the path is compared.
+func samePlaylist(saved string, u *url.URL) bool {
+	savedURL, err := url.Parse(saved)
+	return err == nil && savedURL.Path == u.Path
+}
+
+// fetchPlaylist fetches and parses the playlist from the first working URL
+// of playlistURLs and returns it with the URL that served it.
+func (d *HLSDownloader) fetchPlaylist(playlistURLs []string) (m3u8.Playlist, m3u8.ListType, *url.URL, error) {
+	// Fetch the playlist from the first node that answers with one
+	resp, err := hdrezka.OpenAlternatives(d.client, playlistURLs, d.Headers, int64(len("#EXTM3U")))
 	if err != nil {
-		return err
+		return nil, 0, nil, err
 	}
 	defer resp.Body.Close()
 
 	// Parse the playlist
 	playlist, listType, err := m3u8.DecodeFrom(resp.Body, true)
 	if err != nil {
-		return fmt.Errorf("failed to parse playlist: %w", err)
+		return nil, 0, nil, fmt.Errorf("failed to parse playlist: %w", err)
+	}
+	// Segments live on the node that served the playlist
+	return playlist, listType, resp.Request.URL, nil
+}
+
+// fetchMediaPlaylist fetches the playlist from the first working URL and,
+// for a master playlist, follows the variant d.Variant picks. It returns
+// the media playlist and the URL it was served from.
+func (d *HLSDownloader) fetchMediaPlaylist(playlistURLs []string) (*m3u8.MediaPlaylist, *url.URL, error) {
+	playlist, listType, playlistURL, err := d.fetchPlaylist(playlistURLs)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// Handle playlist based on its type
 	switch listType {
 	case m3u8.MASTER:
-		// Handle master playlist - select the best quality variant
-		masterpl := playlist.(*m3u8.MasterPlaylist)
-		if len(masterpl.Variants) == 0 {
-			return errors.New("no variants found in master playlist")
-		}
-
-		// Select the best quality (highest bandwidth)
-		bestVariant := masterpl.Variants[0]
-		bestBandwidth := bestVariant.Bandwidth
-
-		for _, variant := range masterpl.Variants {
-			if variant.Bandwidth > bestBandwidth {
-				bestBandwidth = variant.Bandwidth
-				bestVariant = variant
-			}
+		variant, err := d.Variant.choose(playlist.(*m3u8.MasterPlaylist).Variants)
+		if err != nil {
+			return nil, nil, err
 		}
 
 		// Get absolute URL for the selected variant
-		variantURL, err := resolveURL(playlistURL, bestVariant.URI)
+		variantURL, err := resolveURL(playlistURL.String(), variant.URI)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 
-		// Download the media playlist
-		return d.downloadPlaylist(variantURL, out)
+		// Fetch the media playlist
+		return d.fetchMediaPlaylist([]string{variantURL})
 
 	case m3u8.MEDIA:
-		// Handle media playlist - download all segments
-		mediapl := playlist.(*m3u8.MediaPlaylist)
-
-		baseURL, err := url.Parse(playlistURL)
-		if err != nil {
-			return fmt.Errorf("failed to parse playlist URL: %w", err)
-		}
-
-		return d.downloadSegments(mediapl, baseURL, out)
+		return playlist.(*m3u8.MediaPlaylist), playlistURL, nil
 
 	default:
-		return errors.New("unknown playlist type")
+		return nil, nil, errors.New("unknown playlist type")
 	}
 }
 
-// downloadSegments downloads all segments from a media playlist
-func (d *HLSDownloader) downloadSegments(playlist *m3u8.MediaPlaylist, baseURL *url.URL, out io.Writer) error {
-	// Count non-nil segments
-	totalSegments := 0
-	for _, segment := range playlist.Segments {
-		if segment != nil {
-			totalSegments++
+// segmentSource resolves segment URLs from the current media playlist.
+// A refresh after expired links replaces it for every worker at once.
+type segmentSource struct {
+	d          *HLSDownloader
+	keys       hlsKeys
+	resolve    playlistResolver
+	mu         sync.Mutex
+	playlist   *m3u8.MediaPlaylist
+	baseURL    *url.URL
+	generation int
+}
+
+// segmentRef is where to fetch a segment from and how to decrypt it.
+type segmentRef struct {
+	URL string
+	// Key is the EXT-X-KEY in effect, nil for clear segments, and KeyURL
+	// its resolved URI.
+	Key    *m3u8.Key
+	KeyURL string
+	// Sequence is the media sequence number, the default IV.
+	Sequence uint64
+}
+
+// segment returns segment i and the playlist generation it came from.
+func (s *segmentSource) segment(i int) (segmentRef, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i >= len(s.playlist.Segments) || s.playlist.Segments[i] == nil {
+		return segmentRef{}, s.generation, fmt.Errorf("refreshed playlist has no segment %d", i)
+	}
+	segmentURL, err := resolveURL(s.baseURL.String(), s.playlist.Segments[i].URI)
+	if err != nil {
+		return segmentRef{}, s.generation, fmt.Errorf("failed to resolve segment URL %s: %w", s.playlist.Segments[i].URI, err)
+	}
+	ref := segmentRef{URL: segmentURL, Sequence: s.playlist.SeqNo + uint64(i)}
+	if key := segmentKey(s.playlist, i); key != nil && !strings.EqualFold(key.Method, keyMethodNone) {
+		ref.Key = key
+		if ref.KeyURL, err = resolveURL(s.baseURL.String(), key.URI); err != nil {
+			return segmentRef{}, s.generation, fmt.Errorf("failed to resolve key URL %s: %w", key.URI, err)
 		}
 	}
+	return ref, s.generation, nil
+}
 
-	// Initialize progress info
-	HLSprogressInfo := HLSProgressInfo{
-		TotalSegments: totalSegments,
+// refresh fetches a fresh playlist unless another worker already replaced
+// the given generation. Segments keep their index in the new playlist.
+func (s *segmentSource) refresh(ctx context.Context, generation int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if generation != s.generation {
+		return nil
+	}
+	fresh, err := s.d.refresh(ctx)
+	if err != nil {
+		return err
+	}
+	playlist, baseURL, err := s.resolve(fresh)
+	if err != nil {
+		return err
+	}
+	if err := checkEncryption(playlist); err != nil {
+		return err
 	}
+	s.playlist, s.baseURL = playlist, baseURL
+	s.generation++
+	return nil
+}
 
-	// Get file for syncing if output is a file
-	outFile, isFile := out.(*os.File)
+// segmentResult is a downloaded segment waiting to be written.
+type segmentResult struct {
+	data []byte
+	err  error
+}
 
-	// Download segments sequentially
-	segmentIndex := 0
-	for i, segment := range playlist.Segments {
-		if segment == nil {
-			continue
+// downloadSegments downloads the segments of source's playlist after the
+// state.Completed ones already in out, the file at outputPath. Up to Jobs
+// segments are fetched at once and written to out in playlist order; at most
+// twice as many are held in memory. state is updated and saved next to
+// outputPath after every written segment.
+func (d *HLSDownloader) downloadSegments(source *segmentSource, out io.Writer, outputPath string, state *hlsState) error {
+	// Indexes of non-nil segments
+	var indexes []int
+	for i, segment := range source.playlist.Segments {
+		if segment != nil {
+			indexes = append(indexes, i)
 		}
+	}
 
-		// Resolve segment URL
-		segmentURL, err := resolveURL(baseURL.String(), segment.URI)
-		if err != nil {
-			return fmt.Errorf("failed to resolve segment URL %s: %w", segment.URI, err)
-		}
+	// Initialize progress info
+	HLSprogressInfo := HLSProgressInfo{
+		Output:          outputPath,
+		TotalSegments:   len(indexes),
+		DownloadedBytes: state.Offset,
+		CurrentSegment:  state.Completed,
+	}
+	indexes = indexes[state.Completed:]
 
-		// Download segment with retries
-		var data []byte
-		for attempt := 0; attempt <= d.RetryAttempts; attempt++ {
-			if attempt > 0 {
-				// Wait before retry
-				time.Sleep(d.RetryDelay)
-			}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-			data, err = d.downloadSegment(segmentURL)
-			if err == nil {
-				break // Successfully downloaded
+	// Workers take segments in playlist order, so the segment the writer
+	// waits for is never stuck behind later ones
+	type task struct {
+		index  int
+		result chan segmentResult
+	}
+	jobs := max(d.Jobs, 1)
+	queue := make(chan task, 2*jobs)
+	defer close(queue)
+	for range jobs {
+		go func() {
+			for t := range queue {
+				if ctx.Err() != nil {
+					t.result <- segmentResult{err: ctx.Err()}
+					continue
+				}
+				data, err := d.fetchSegment(ctx, source, t.index)
+				t.result <- segmentResult{data, err}
 			}
+		}()
+	}
+	pending := make([]chan segmentResult, len(indexes))
+	start := func(n int) {
+		pending[n] = make(chan segmentResult, 1)
+		queue <- task{indexes[n], pending[n]}
+	}
 
-			// If this was the last attempt, return the error
-			if attempt == d.RetryAttempts {
-				return fmt.Errorf("failed to download segment %d after %d attempts: %w",
-					i, d.RetryAttempts+1, err)
-			}
+	// Keep a window of segments queued ahead of the writer, so a slow
+	// segment does not leave the other workers idle
+	next := 0
+	for ; next < len(indexes) && next < 2*jobs; next++ {
+		start(next)
+	}
+	for n := range indexes {
+		result := <-pending[n]
+		pending[n] = nil
+		if result.err != nil {
+			return result.err
+		}
+		if next < len(indexes) {
+			start(next)
+			next++
 		}
 
 		// Write segment to file
-		_, err = out.Write(data)
-		if err != nil {
-			return fmt.Errorf("failed to write segment %d: %w", segmentIndex, err)
+		if _, err := out.Write(result.data); err != nil {
+			return fmt.Errorf("failed to write segment %d: %w", indexes[n], err)
 		}
-
-		// Sync file to disk if it's a file
-		if isFile {
-			if err := outFile.Sync(); err != nil {
-				return fmt.Errorf("failed to sync file after segment %d: %w", segmentIndex, err)
-			}
+		state.Completed++
+		state.Offset += int64(len(result.data))
+		if err := state.save(outputPath); err != nil {
+			return fmt.Errorf("failed to save download state: %w", err)
 		}
 
 		// Update progress info
-		HLSprogressInfo.DownloadedBytes += int64(len(data))
-		HLSprogressInfo.CurrentSegment = segmentIndex + 1
+		HLSprogressInfo.DownloadedBytes = state.Offset
+		HLSprogressInfo.CurrentSegment = state.Completed
 
 		// Call progress callback
 		if d.progressCallback != nil {
 			d.progressCallback(HLSprogressInfo)
 		}
+	}
 
-		segmentIndex++
+	state.Complete = true
+	if err := state.save(outputPath); err != nil {
+		return fmt.Errorf("failed to save download state: %w", err)
 	}
 
 	// Final progress update
@@ -230,21 +415,81 @@ func (d *HLSDownloader) downloadSegments(playlist *m3u8.MediaPlaylist, baseURL *
 	return nil
 }
 
-// downloadSegment downloads a single segment
-func (d *HLSDownloader) downloadSegment(segmentURL string) ([]byte, error) {
-	resp, err := d.makeRequest(segmentURL)
+// fetchSegment downloads and decrypts segment i with retries. Expired
+// links fetch a fresh playlist once, without using up a retry, and the
+// same segment is tried again.
+func (d *HLSDownloader) fetchSegment(ctx context.Context, source *segmentSource, i int) ([]byte, error) {
+	ref, generation, err := source.segment(i)
+	if err != nil {
+		return nil, err
+	}
+	refreshed := false
+	for attempt := 0; ; {
+		var data []byte
+		data, err = d.downloadSegment(ctx, &source.keys, ref)
+		if err == nil {
+			return data, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		// Expired links get one refresh that does not use up a retry
+		if d.refresh != nil && isLinkExpired(err) && !refreshed {
+			refreshed = true
+			if err := source.refresh(ctx, generation); err != nil {
+				return nil, fmt.Errorf("failed to refresh expired playlist at segment %d: %w", i, err)
+			}
+			if ref, generation, err = source.segment(i); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if attempt++; attempt > d.RetryAttempts {
+			break
+		}
+		// Wait before retry
+		select {
+		case <-time.After(d.RetryDelay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return nil, fmt.Errorf("failed to download segment %d after %d attempts: %w", i, d.RetryAttempts+1, err)
+}
+
+// downloadSegment downloads a single segment and decrypts it
+func (d *HLSDownloader) downloadSegment(ctx context.Context, keys *hlsKeys, ref segmentRef) ([]byte, error) {
+	resp, err := d.makeRequest(ctx, ref.URL)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	// Read segment data
-	return io.ReadAll(resp.Body)
+	data, err := io.ReadAll(resp.Body)
+	if err != nil || ref.Key == nil {
+		return data, err
+	}
+
+	if !strings.EqualFold(ref.Key.Method, keyMethodAES128) {
+		return nil, fmt.Errorf("unsupported HLS encryption method %s", ref.Key.Method)
+	}
+	key, err := keys.get(ctx, ref.KeyURL)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := segmentIV(ref.Key, ref.Sequence)
+	if err != nil {
+		return nil, err
+	}
+	return decryptAES128(data, key, iv)
 }
 
 // makeRequest makes an HTTP request with configured headers and timeout
-func (d *HLSDownloader) makeRequest(url string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func (d *HLSDownloader) makeRequest(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -264,7 +509,7 @@ func (d *HLSDownloader) makeRequest(url string) (*http.Response, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		resp.Body.Close()
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, &hdrezka.StatusError{URL: url, StatusCode: resp.StatusCode}
 	}
 
 	return resp, nil
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mediaPlaylist returns a playlist of n segments named "0.ts", "1.ts"...
+// with extra lines inserted before the segment they are keyed by.
+func mediaPlaylist(n int, extra map[int]string) string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:5\n#EXT-X-MEDIA-SEQUENCE:0\n")
+	for i := range n {
+		if line, found := extra[i]; found {
+			b.WriteString(line + "\n")
+		}
+		fmt.Fprintf(&b, "#EXTINF:5,\n%d.ts\n", i)
+	}
+	b.WriteString("#EXT-X-ENDLIST\n")
+	return b.String()
+}
+
+// segmentIndex returns the index of a "/<n>.ts" request, or -1.
+func segmentIndex(req *http.Request) int {
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/"), ".ts"))
+	if err != nil || !strings.HasSuffix(req.URL.Path, ".ts") {
+		return -1
+	}
+	return n
+}
+
+func segmentBody(i int) string {
+	return fmt.Sprintf("<segment %d>", i)
+}
+
+// newPeakServer starts a test server running handler and records the most
+// requests it handled at once in peak.
+func newPeakServer(t *testing.T, handler http.HandlerFunc) (srv *httptest.Server, peak *atomic.Int32) {
+	var running atomic.Int32
+	peak = new(atomic.Int32)
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		handler(w, req)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, peak
+}
+
+func TestHLSDownloadOrder(t *testing.T) {
+	t.Parallel()
+
+	const segments = 12
+	srv, peak := newPeakServer(t, func(w http.ResponseWriter, req *http.Request) {
+		i := segmentIndex(req)
+		if i < 0 {
+			fmt.Fprint(w, mediaPlaylist(segments, nil))
+			return
+		}
+		// Later segments of every window answer first
+		time.Sleep(time.Duration(4-i%4) * 5 * time.Millisecond)
+		fmt.Fprint(w, segmentBody(i))
+	})
+
+	output := filepath.Join(t.TempDir(), "video.ts")
+	d := NewHLSDownloader(srv.Client())
+	d.SetJobs(4)
+	var progress []int
+	d.SetProgressCallback(func(info HLSProgressInfo) {
+		progress = append(progress, info.CurrentSegment)
+	})
+	if err := d.Download([]string{srv.URL + "/index.m3u8"}, output); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	var want strings.Builder
+	for i := range segments {
+		want.WriteString(segmentBody(i))
+	}
+	if got, _ := os.ReadFile(output); string(got) != want.String() {
+		t.Errorf("Download() wrote %q, want the segments in order", got)
+	}
+	if p := peak.Load(); p > 4 {
+		t.Errorf("Download() fetched %d segments at once, want at most 4", p)
+	}
+	if len(progress) != segments+1 || progress[segments] != segments {
+		t.Errorf("Download() progress = %v", progress)
+	}
+	if !hlsComplete(output) {
+		t.Error("Download() did not mark the sidecar complete")
+	}
+}
+
+func TestHLSDownloadErrorCancels(t *testing.T) {
+	t.Parallel()
+
+	var canceled atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch i := segmentIndex(req); {
+		case i < 0:
+			fmt.Fprint(w, mediaPlaylist(8, nil))
+		case i < 2:
+			fmt.Fprint(w, segmentBody(i))
+		case i == 2:
+			http.NotFound(w, req)
+		default:
+			// Hang until the failed download gives up on the request
+			select {
+			case <-req.Context().Done():
+				canceled.Add(1)
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	output := filepath.Join(t.TempDir(), "video.ts")
+	d := NewHLSDownloader(srv.Client())
+	d.SetJobs(4)
+	d.SetRetryAttempts(0)
+	start := time.Now()
+	err := d.Download([]string{srv.URL + "/index.m3u8"}, output)
+	if err == nil || !strings.Contains(err.Error(), "segment 2") {
+		t.Fatalf("Download() error = %v, want the failed segment 2", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Download() took %v to fail, want the hanging segments canceled", elapsed)
+	}
+	for deadline := time.Now().Add(5 * time.Second); canceled.Load() == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if canceled.Load() == 0 {
+		t.Error("Download() left the hanging segment requests running")
+	}
+
+	// The segments before the failure are kept for a resume
+	if got, _ := os.ReadFile(output); string(got) != segmentBody(0)+segmentBody(1) {
+		t.Errorf("Download() kept %q, want the first two segments", got)
+	}
+	if state := loadHLSState(output); state == nil || state.Complete || state.Completed != 2 {
+		t.Errorf("Download() sidecar = %+v, want 2 of 8 segments", state)
+	}
+}
+
+func TestHLSDownloadResume(t *testing.T) {
+	t.Parallel()
+
+	var fetched atomic.Int64 // bit per segment
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		i := segmentIndex(req)
+		if i < 0 {
+			fmt.Fprint(w, mediaPlaylist(6, nil))
+			return
+		}
+		fetched.Or(1 << i)
+		fmt.Fprint(w, segmentBody(i))
+	}))
+	t.Cleanup(srv.Close)
+
+	var full strings.Builder
+	for i := range 6 {
+		full.WriteString(segmentBody(i))
+	}
+	done := segmentBody(0) + segmentBody(1) + segmentBody(2)
+
+	tests := []struct {
+		name        string
+		state       *hlsState
+		existing    string
+		wantFetched int64
+	}{
+		{
+			name:        "resume after the last recorded segment",
+			state:       &hlsState{PlaylistURL: "https://other-node.example.com/index.m3u8?sig=old", Segments: 6, Completed: 3, Offset: int64(len(done))},
+			existing:    done + "<half of segment 3",
+			wantFetched: 0b111000,
+		},
+		{
+			name:        "another playlist with as many segments",
+			state:       &hlsState{PlaylistURL: "https://other-node.example.com/720/index.m3u8", Segments: 6, Completed: 3, Offset: int64(len(done))},
+			existing:    done,
+			wantFetched: 0b111111,
+		},
+		{
+			name:        "file shorter than recorded",
+			state:       &hlsState{PlaylistURL: "/index.m3u8", Segments: 6, Completed: 3, Offset: int64(len(done))},
+			existing:    segmentBody(0),
+			wantFetched: 0b111111,
+		},
+		{
+			name:        "complete file is skipped",
+			state:       &hlsState{PlaylistURL: "/index.m3u8", Segments: 6, Completed: 6, Offset: int64(full.Len()), Complete: true},
+			existing:    full.String(),
+			wantFetched: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Runs one at a time to attribute the fetched segments
+			fetched.Store(0)
+			output := filepath.Join(t.TempDir(), "video.ts")
+			if err := os.WriteFile(output, []byte(tt.existing), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.state.save(output); err != nil {
+				t.Fatal(err)
+			}
+			d := NewHLSDownloader(srv.Client())
+			d.SetJobs(2)
+			if err := d.Download([]string{srv.URL + "/index.m3u8"}, output); err != nil {
+				t.Fatalf("Download() error = %v", err)
+			}
+			if got, _ := os.ReadFile(output); string(got) != full.String() {
+				t.Errorf("Download() wrote %q, want %q", got, full.String())
+			}
+			if got := fetched.Load(); got != tt.wantFetched {
+				t.Errorf("Download() fetched segments %06b, want %06b", got, tt.wantFetched)
+			}
+			if !hlsComplete(output) {
+				t.Error("Download() did not mark the sidecar complete")
+			}
+		})
+	}
+}
+
+func TestHLSDownloadAES128(t *testing.T) {
+	t.Parallel()
+
+	key1, key2 := []byte("0123456789abcdef"), []byte("fedcba9876543210")
+	explicitIV := bytes.Repeat([]byte{0xab}, aes.BlockSize)
+	const sequence = 10
+	playlist := strings.Replace(mediaPlaylist(6, map[int]string{
+		0: `#EXT-X-KEY:METHOD=AES-128,URI="key1"`,
+		3: `#EXT-X-KEY:METHOD=AES-128,URI="key2",IV=0x` + hex.EncodeToString(explicitIV),
+		5: `#EXT-X-KEY:METHOD=NONE`,
+	}), "#EXT-X-MEDIA-SEQUENCE:0", fmt.Sprintf("#EXT-X-MEDIA-SEQUENCE:%d", sequence), 1)
+
+	var keyRequests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/index.m3u8":
+			fmt.Fprint(w, playlist)
+			return
+		case "/sample.m3u8":
+			fmt.Fprint(w, mediaPlaylist(2, map[int]string{1: `#EXT-X-KEY:METHOD=SAMPLE-AES,URI="key1"`}))
+			return
+		case "/key1", "/key2":
+			keyRequests.Add(1)
+			if req.Header.Get("Cookie") != "dle_user_id=1" {
+				http.Error(w, "login required", http.StatusForbidden)
+				return
+			}
+			if req.URL.Path == "/key1" {
+				w.Write(key1)
+			} else {
+				w.Write(key2)
+			}
+			return
+		}
+		i := segmentIndex(req)
+		data := []byte(segmentBody(i))
+		switch {
+		case i < 3:
+			iv := make([]byte, aes.BlockSize)
+			binary.BigEndian.PutUint64(iv[8:], uint64(sequence+i))
+			data = encryptAES128(data, key1, iv)
+		case i < 5:
+			data = encryptAES128(data, key2, explicitIV)
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	d := NewHLSDownloader(srv.Client())
+	d.SetJobs(4)
+	d.Headers.Set("Cookie", "dle_user_id=1")
+	output := filepath.Join(dir, "video.ts")
+	if err := d.Download([]string{srv.URL + "/index.m3u8"}, output); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	var want strings.Builder
+	for i := range 6 {
+		want.WriteString(segmentBody(i))
+	}
+	if got, _ := os.ReadFile(output); string(got) != want.String() {
+		t.Errorf("Download() wrote %q, want the decrypted segments", got)
+	}
+	if n := keyRequests.Load(); n != 2 {
+		t.Errorf("Download() made %d key requests, want one per key", n)
+	}
+
+	// Nothing is written for a method that cannot be decrypted
+	output = filepath.Join(dir, "sample.ts")
+	if err := d.Download([]string{srv.URL + "/sample.m3u8"}, output); err == nil || !strings.Contains(err.Error(), "SAMPLE-AES") {
+		t.Errorf("Download() of SAMPLE-AES error = %v", err)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("Download() of SAMPLE-AES created %s", output)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/grafov/m3u8"
+)
+
+// Encryption methods of EXT-X-KEY
+const (
+	keyMethodNone   = "NONE"
+	keyMethodAES128 = "AES-128"
+)
+
+// segmentKey returns the EXT-X-KEY in effect for segment i. The parser only
+// attaches a key to the segment right after its tag, and it applies until
+// the next one.
+func segmentKey(playlist *m3u8.MediaPlaylist, i int) *m3u8.Key {
+	for ; i >= 0; i-- {
+		if segment := playlist.Segments[i]; segment != nil && segment.Key != nil {
+			return segment.Key
+		}
+	}
+	return nil
+}
+
+// checkEncryption fails for playlists using an encryption method other
+// than AES-128, before anything is written. SAMPLE-AES is not supported: it
+// encrypts samples inside the container, which would need the TS stream to
+// be demuxed.
+func checkEncryption(playlist *m3u8.MediaPlaylist) error {
+	for _, segment := range playlist.Segments {
+		if segment == nil || segment.Key == nil {
+			continue
+		}
+		switch method := strings.ToUpper(segment.Key.Method); method {
+		case "", keyMethodNone, keyMethodAES128:
+		default:
+			return fmt.Errorf("unsupported HLS encryption method %s", segment.Key.Method)
+		}
+	}
+	return nil
+}
+
+// hlsKeys fetches AES-128 keys through the downloader's client, so the
+// site cookies and headers apply, and caches them by URL. Workers needing
+// a key that is being fetched wait for that request instead of making
+// their own.
+type hlsKeys struct {
+	d    *HLSDownloader
+	mu   sync.Mutex
+	keys map[string]*keyFetch
+}
+
+// keyFetch is a key request shared by every worker that needs the key.
+type keyFetch struct {
+	done chan struct{}
+	key  []byte
+	err  error
+}
+
+// get returns the key served at keyURL.
+func (k *hlsKeys) get(ctx context.Context, keyURL string) ([]byte, error) {
+	k.mu.Lock()
+	fetch, found := k.keys[keyURL]
+	if !found {
+		fetch = &keyFetch{done: make(chan struct{})}
+		if k.keys == nil {
+			k.keys = make(map[string]*keyFetch)
+		}
+		k.keys[keyURL] = fetch
+	}
+	k.mu.Unlock()
+
+	if !found {
+		fetch.key, fetch.err = k.fetch(ctx, keyURL)
+		if fetch.err != nil {
+			// Let a retry request the key again
+			k.mu.Lock()
+			delete(k.keys, keyURL)
+			k.mu.Unlock()
+		}
+		close(fetch.done)
+	}
+	select {
+	case <-fetch.done:
+		return fetch.key, fetch.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetch requests the key served at keyURL.
+func (k *hlsKeys) fetch(ctx context.Context, keyURL string) ([]byte, error) {
+	resp, err := k.d.makeRequest(ctx, keyURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch key: %w", err)
+	}
+	defer resp.Body.Close()
+	key, err := io.ReadAll(io.LimitReader(resp.Body, aes.BlockSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch key: %w", err)
+	}
+	if len(key) != aes.BlockSize {
+		return nil, fmt.Errorf("key %s has %d bytes, want %d", keyURL, len(key), aes.BlockSize)
+	}
+	return key, nil
+}
+
+// segmentIV returns the explicit IV of key, or the media sequence number
+// of the segment as a big-endian 128-bit integer when there is none.
+func segmentIV(key *m3u8.Key, sequence uint64) ([]byte, error) {
+	iv := make([]byte, aes.BlockSize)
+	if key.IV == "" {
+		binary.BigEndian.PutUint64(iv[8:], sequence)
+		return iv, nil
+	}
+	digits := strings.TrimPrefix(strings.TrimPrefix(key.IV, "0x"), "0X")
+	if len(digits) > 2*aes.BlockSize {
+		return nil, fmt.Errorf("invalid IV %s", key.IV)
+	}
+	if len(digits)%2 == 1 {
+		digits = "0" + digits
+	}
+	b, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IV %s", key.IV)
+	}
+	copy(iv[aes.BlockSize-len(b):], b)
+	return iv, nil
+}
+
+// decryptAES128 decrypts an AES-128-CBC segment and strips its PKCS#7
+// padding.
+func decryptAES128(data, key, iv []byte) ([]byte, error) {
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted segment size %d is not a multiple of the block size", len(data))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, data)
+	padding := int(data[len(data)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, errors.New("invalid padding in decrypted segment, wrong key or IV")
+	}
+	for _, b := range data[len(data)-padding:] {
+		if int(b) != padding {
+			return nil, errors.New("invalid padding in decrypted segment, wrong key or IV")
+		}
+	}
+	return data[:len(data)-padding], nil
+}
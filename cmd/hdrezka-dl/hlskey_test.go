@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafov/m3u8"
+)
+
+// encryptAES128 pads data with PKCS#7 and encrypts it with AES-128-CBC.
+func encryptAES128(data, key, iv []byte) []byte {
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	out := append(bytes.Clone(data), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	block, _ := aes.NewCipher(key)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, out)
+	return out
+}
+
+func TestSegmentKey(t *testing.T) {
+	t.Parallel()
+
+	first := &m3u8.Key{Method: "AES-128", URI: "k1"}
+	second := &m3u8.Key{Method: "AES-128", URI: "k2"}
+	playlist := &m3u8.MediaPlaylist{Segments: []*m3u8.MediaSegment{
+		{URI: "0.ts"},
+		{URI: "1.ts", Key: first},
+		{URI: "2.ts"},
+		{URI: "3.ts", Key: second},
+		{URI: "4.ts"},
+	}}
+	want := []*m3u8.Key{nil, first, first, second, second}
+	for i, key := range want {
+		if got := segmentKey(playlist, i); got != key {
+			t.Errorf("segmentKey(%d) = %+v, want %+v", i, got, key)
+		}
+	}
+}
+
+func TestCheckEncryption(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		method  string
+		wantErr bool
+	}{
+		{"", false},
+		{"NONE", false},
+		{"AES-128", false},
+		{"aes-128", false},
+		{"SAMPLE-AES", true},
+		{"SAMPLE-AES-CTR", true},
+	}
+	for _, tt := range tests {
+		playlist := &m3u8.MediaPlaylist{Segments: []*m3u8.MediaSegment{
+			{URI: "0.ts"},
+			{URI: "1.ts", Key: &m3u8.Key{Method: tt.method, URI: "key"}},
+			nil,
+		}}
+		if err := checkEncryption(playlist); (err != nil) != tt.wantErr {
+			t.Errorf("checkEncryption(%q) error = %v, want error %v", tt.method, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSegmentIV(t *testing.T) {
+	t.Parallel()
+
+	sequence := func(n byte) []byte {
+		iv := make([]byte, aes.BlockSize)
+		iv[aes.BlockSize-1] = n
+		return iv
+	}
+	tests := []struct {
+		name     string
+		iv       string
+		sequence uint64
+		want     []byte
+		wantErr  bool
+	}{
+		{"sequence number", "", 7, sequence(7), false},
+		{"explicit", "0x000102030405060708090A0B0C0D0E0F", 7, []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}, false},
+		{"short explicit", "0X1", 7, sequence(1), false},
+		{"too long", "0x" + strings.Repeat("00", 17), 7, nil, true},
+		{"not hex", "0xZZ", 7, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := segmentIV(&m3u8.Key{Method: "AES-128", IV: tt.iv}, tt.sequence)
+			if (err != nil) != tt.wantErr || !bytes.Equal(got, tt.want) {
+				t.Errorf("segmentIV(%q, %d) = %x, %v, want %x", tt.iv, tt.sequence, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecryptAES128(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("0123456789abcdef")
+	iv := make([]byte, aes.BlockSize)
+	tests := []struct {
+		name    string
+		data    []byte
+		key     []byte
+		want    []byte
+		wantErr bool
+	}{
+		{"padded", encryptAES128([]byte("segment"), key, iv), key, []byte("segment"), false},
+		{"full padding block", encryptAES128(bytes.Repeat([]byte("x"), 16), key, iv), key, bytes.Repeat([]byte("x"), 16), false},
+		{"wrong key", encryptAES128([]byte("segment"), key, iv), []byte("fedcba9876543210"), nil, true},
+		{"not block aligned", []byte("short"), key, nil, true},
+		{"empty", nil, key, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := decryptAES128(tt.data, tt.key, iv)
+			if (err != nil) != tt.wantErr || !bytes.Equal(got, tt.want) {
+				t.Errorf("decryptAES128() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHLSKeysShareFetch(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		if req.URL.Path == "/short" {
+			w.Write([]byte("short"))
+			return
+		}
+		// Slow enough for every worker to ask while the key is in flight
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("0123456789abcdef"))
+	}))
+	t.Cleanup(srv.Close)
+
+	keys := &hlsKeys{d: NewHLSDownloader(srv.Client())}
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if key, err := keys.get(context.Background(), srv.URL+"/key"); err != nil || string(key) != "0123456789abcdef" {
+				t.Errorf("get() = %q, %v", key, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := requests.Load(); n != 1 {
+		t.Errorf("get() made %d key requests, want 1", n)
+	}
+
+	// A failed fetch is not cached
+	for range 2 {
+		if _, err := keys.get(context.Background(), srv.URL+"/short"); err == nil {
+			t.Error("get() of a short key error = nil")
+		}
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("get() made %d key requests, want the short key requested twice", n-1)
+	}
+}
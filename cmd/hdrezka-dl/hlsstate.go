@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// hlsStateSuffix names the sidecar file that tracks an HLS download.
+const hlsStateSuffix = ".hls.json"
+
+// hlsState is the progress of an HLS download, saved next to the output
+// after every segment so an interrupted download resumes where it stopped.
+type hlsState struct {
+	PlaylistURL string `json:"playlist_url"`
+	Segments    int    `json:"segments"`
+	// Completed is the number of segments written and Offset the output
+	// size after the last of them.
+	Completed int   `json:"completed"`
+	Offset    int64 `json:"offset"`
+	// Complete is only set once every segment is written; a file without
+	// it is partial.
+	Complete bool `json:"complete"`
+}
+
+// loadHLSState reads the sidecar of output, or returns nil when there is
+// none or it is unreadable or inconsistent.
+func loadHLSState(output string) *hlsState {
+	data, err := os.ReadFile(output + hlsStateSuffix)
+	if err != nil {
+		return nil
+	}
+	state := &hlsState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil
+	}
+	if state.Completed < 0 || state.Completed > state.Segments || state.Offset < 0 {
+		return nil
+	}
+	return state
+}
+
+// save replaces the sidecar of output atomically.
+func (s *hlsState) save(output string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(output), filepath.Base(output)+hlsStateSuffix+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), output+hlsStateSuffix)
+}
+
+// hlsComplete reports whether output is a finished HLS download: its
+// sidecar is marked complete and the file has the recorded size.
+func hlsComplete(output string) bool {
+	state := loadHLSState(output)
+	if state == nil || !state.Complete {
+		return false
+	}
+	fileInfo, err := os.Stat(output)
+	return err == nil && fileInfo.Size() == state.Offset
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadHLSState(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		sidecar string
+		wantNil bool
+	}{
+		{"valid", `{"segments":3,"completed":2,"offset":100}`, false},
+		{"not started", `{"segments":3}`, false},
+		{"missing", "", true},
+		{"corrupt", `{"segments":`, true},
+		{"completed past segments", `{"segments":3,"completed":4,"offset":100}`, true},
+		{"negative completed", `{"segments":3,"completed":-1}`, true},
+		{"negative offset", `{"segments":3,"completed":1,"offset":-5}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			output := filepath.Join(t.TempDir(), "video.ts")
+			if tt.sidecar != "" {
+				if err := os.WriteFile(output+hlsStateSuffix, []byte(tt.sidecar), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := loadHLSState(output); (got == nil) != tt.wantNil {
+				t.Errorf("loadHLSState() = %+v, want nil %v", got, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestHLSComplete(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		state *hlsState
+		size  int
+		want  bool
+	}{
+		{"complete", &hlsState{Segments: 2, Completed: 2, Offset: 10, Complete: true}, 10, true},
+		{"partial", &hlsState{Segments: 2, Completed: 1, Offset: 5}, 5, false},
+		{"size differs", &hlsState{Segments: 2, Completed: 2, Offset: 10, Complete: true}, 8, false},
+		{"no sidecar", nil, 10, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			output := filepath.Join(t.TempDir(), "video.ts")
+			if err := os.WriteFile(output, make([]byte, tt.size), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.state != nil {
+				if err := tt.state.save(output); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := hlsComplete(output); got != tt.want {
+				t.Errorf("hlsComplete() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSamePlaylist(t *testing.T) {
+	t.Parallel()
+
+	u, _ := url.Parse("https://node2.example.com/s/1080.mp4/index.m3u8?sig=new")
+	tests := []struct {
+		saved string
+		want  bool
+	}{
+		{"https://node1.example.com/s/1080.mp4/index.m3u8?sig=old", true},
+		{"https://node2.example.com/s/720.mp4/index.m3u8?sig=new", false},
+		{"", false},
+		{"%zz", false},
+	}
+	for _, tt := range tests {
+		if got := samePlaylist(tt.saved, u); got != tt.want {
+			t.Errorf("samePlaylist(%q) = %v, want %v", tt.saved, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/grafov/m3u8"
+)
+
+// VariantFilter picks a variant of a master playlist. Among the variants
+// within every set limit the one with the highest bandwidth wins; the zero
+// value picks the highest bandwidth overall.
+type VariantFilter struct {
+	MaxHeight    int    // Tallest resolution allowed, e.g. 720
+	MaxBandwidth uint32 // Highest BANDWIDTH allowed in bits per second
+	Codec        string // Codec the variant must use, e.g. avc1 or hevc
+}
+
+// codecAliases maps common codec names to their CODECS prefixes
+var codecAliases = map[string][]string{
+	"h264": {"avc1", "avc3"},
+	"avc":  {"avc1", "avc3"},
+	"h265": {"hvc1", "hev1"},
+	"hevc": {"hvc1", "hev1"},
+	"av1":  {"av01"},
+	"aac":  {"mp4a"},
+}
+
+// HLSVariant is a variant stream of a master playlist.
+type HLSVariant struct {
+	URI        string
+	Bandwidth  uint32
+	Width      int
+	Height     int
+	Codecs     string
+	FrameRate  float64
+	AudioGroup string
+}
+
+// HLSRendition is an alternative audio, subtitle or video rendition
+// declared with EXT-X-MEDIA. Renditions without a URI are muxed into the
+// variants.
+type HLSRendition struct {
+	Type     string
+	GroupID  string
+	Language string
+	Name     string
+	URI      string
+	Default  bool
+}
+
+// HLSInfo lists what a master playlist offers. Both lists are empty for a
+// media playlist.
+type HLSInfo struct {
+	Variants   []HLSVariant
+	Renditions []HLSRendition
+}
+
+// parseResolution splits a RESOLUTION attribute such as "1280x720".
+func parseResolution(resolution string) (width, height int) {
+	w, h, found := strings.Cut(resolution, "x")
+	if !found {
+		return 0, 0
+	}
+	width, _ = strconv.Atoi(w)
+	height, _ = strconv.Atoi(h)
+	return width, height
+}
+
+// matches reports whether a variant is within the filter limits.
+func (f VariantFilter) matches(variant *m3u8.Variant) bool {
+	if variant.Iframe {
+		return false
+	}
+	if f.MaxBandwidth > 0 && variant.Bandwidth > f.MaxBandwidth {
+		return false
+	}
+	if f.MaxHeight > 0 {
+		// Variants without a resolution are kept, there is nothing to
+		// compare
+		if _, height := parseResolution(variant.Resolution); height > f.MaxHeight {
+			return false
+		}
+	}
+	if f.Codec != "" {
+		want := strings.ToLower(f.Codec)
+		prefixes := codecAliases[want]
+		if prefixes == nil {
+			prefixes = []string{want}
+		}
+		codecs := strings.Split(strings.ToLower(variant.Codecs), ",")
+		if !slices.ContainsFunc(codecs, func(codec string) bool {
+			return slices.ContainsFunc(prefixes, func(prefix string) bool {
+				return strings.HasPrefix(strings.TrimSpace(codec), prefix)
+			})
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// choose returns the variant the filter picks.
+func (f VariantFilter) choose(variants []*m3u8.Variant) (*m3u8.Variant, error) {
+	var best *m3u8.Variant
+	for _, variant := range variants {
+		if variant != nil && f.matches(variant) && (best == nil || variant.Bandwidth > best.Bandwidth) {
+			best = variant
+		}
+	}
+	if best != nil {
+		return best, nil
+	}
+	if len(variants) == 0 {
+		return nil, errors.New("no variants found in master playlist")
+	}
+	var available []string
+	for _, variant := range variants {
+		if variant != nil && !variant.Iframe {
+			available = append(available, fmt.Sprintf("%s %d %s", variant.Resolution, variant.Bandwidth, variant.Codecs))
+		}
+	}
+	return nil, fmt.Errorf("no variant matches the filter, available: %s", strings.Join(available, "; "))
+}
+
+// Inspect lists the variants and renditions of the playlist served at the
+// first working URL of playlistURLs.
+func (d *HLSDownloader) Inspect(playlistURLs []string) (*HLSInfo, error) {
+	playlist, listType, _, err := d.fetchPlaylist(playlistURLs)
+	if err != nil {
+		return nil, err
+	}
+	info := &HLSInfo{}
+	if listType != m3u8.MASTER {
+		return info, nil
+	}
+	for _, variant := range playlist.(*m3u8.MasterPlaylist).Variants {
+		if variant == nil || variant.Iframe {
+			continue
+		}
+		width, height := parseResolution(variant.Resolution)
+		info.Variants = append(info.Variants, HLSVariant{
+			URI:        variant.URI,
+			Bandwidth:  variant.Bandwidth,
+			Width:      width,
+			Height:     height,
+			Codecs:     variant.Codecs,
+			FrameRate:  variant.FrameRate,
+			AudioGroup: variant.Audio,
+		})
+		for _, alt := range variant.Alternatives {
+			rendition := HLSRendition{
+				Type:     alt.Type,
+				GroupID:  alt.GroupId,
+				Language: alt.Language,
+				Name:     alt.Name,
+				URI:      alt.URI,
+				Default:  alt.Default,
+			}
+			// Every variant repeats the renditions declared before it
+			if !slices.Contains(info.Renditions, rendition) {
+				info.Renditions = append(info.Renditions, rendition)
+			}
+		}
+	}
+	return info, nil
+}
+
+// DownloadAudio saves the alternate audio renditions of the chosen variant
+// whose language or name is in languages, or all of them for "all", next to
+// output as "<name>.<language>.audio.ts". Like Download it resumes partial
+// files and skips complete ones. It returns the saved files. Renditions
+// muxed into the variant have no playlist and are skipped.
+func (d *HLSDownloader) DownloadAudio(playlistURLs []string, languages []string, output string) ([]string, error) {
+	playlist, listType, _, err := d.fetchPlaylist(playlistURLs)
+	if err != nil {
+		return nil, err
+	}
+	if listType != m3u8.MASTER {
+		return nil, nil
+	}
+	variant, err := d.Variant.choose(playlist.(*m3u8.MasterPlaylist).Variants)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved []string
+	base := strings.TrimSuffix(output, filepath.Ext(output))
+	for _, alt := range variant.Alternatives {
+		if alt.Type != "AUDIO" || alt.URI == "" || (variant.Audio != "" && alt.GroupId != variant.Audio) ||
+			!wantRendition(alt, languages) {
+			continue
+		}
+		label := alt.Language
+		if label == "" {
+			label = alt.Name
+		}
+		audioOutput := fmt.Sprintf("%s.%s.audio.ts", base, sanitizeFilename(label))
+		// The parser may list a rendition more than once per variant
+		if slices.Contains(saved, audioOutput) {
+			continue
+		}
+		if err := d.download(playlistURLs, audioOutput, d.renditionResolver(alt)); err != nil {
+			return saved, fmt.Errorf("audio %s: %w", label, err)
+		}
+		saved = append(saved, audioOutput)
+	}
+	return saved, nil
+}
+
+// wantRendition reports whether languages selects a rendition by language
+// or name.
+func wantRendition(alt *m3u8.Alternative, languages []string) bool {
+	for _, lang := range languages {
+		lang = strings.TrimSpace(lang)
+		if strings.EqualFold(lang, "all") || strings.EqualFold(lang, alt.Language) || strings.EqualFold(lang, alt.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// renditionResolver resolves the media playlist of a rendition from a
+// fresh master playlist, whose URIs may carry new signatures.
+func (d *HLSDownloader) renditionResolver(want *m3u8.Alternative) playlistResolver {
+	return func(playlistURLs []string) (*m3u8.MediaPlaylist, *url.URL, error) {
+		playlist, listType, playlistURL, err := d.fetchPlaylist(playlistURLs)
+		if err != nil {
+			return nil, nil, err
+		}
+		if listType != m3u8.MASTER {
+			return nil, nil, errors.New("playlist is no longer a master playlist")
+		}
+		for _, variant := range playlist.(*m3u8.MasterPlaylist).Variants {
+			for _, alt := range variant.Alternatives {
+				if alt.Type == want.Type && alt.GroupId == want.GroupId && alt.Name == want.Name &&
+					alt.Language == want.Language && alt.URI != "" {
+					renditionURL, err := resolveURL(playlistURL.String(), alt.URI)
+					if err != nil {
+						return nil, nil, err
+					}
+					return d.fetchMediaPlaylist([]string{renditionURL})
+				}
+			}
+		}
+		return nil, nil, fmt.Errorf("rendition %s not found in master playlist", want.Name)
+	}
+}
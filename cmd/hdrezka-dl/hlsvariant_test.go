@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grafov/m3u8"
+)
+
+const masterFixture = `#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",LANGUAGE="ru",NAME="Русский",DEFAULT=YES,AUTOSELECT=YES
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",LANGUAGE="en",NAME="English",URI="audio/en.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,CODECS="avc1.4d401e,mp4a.40.2",AUDIO="aud"
+v360.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2500000,RESOLUTION=1280x720,CODECS="avc1.64001f,mp4a.40.2",AUDIO="aud",FRAME-RATE=25
+v720.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=4000000,RESOLUTION=1920x1080,CODECS="hvc1.1.6.L120,mp4a.40.2",AUDIO="aud"
+v1080.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=9000000,RESOLUTION=1920x1080,URI="iframes.m3u8"
+`
+
+func TestParseResolution(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		resolution    string
+		width, height int
+	}{
+		{"1280x720", 1280, 720},
+		{"", 0, 0},
+		{"720p", 0, 0},
+	}
+	for _, tt := range tests {
+		if w, h := parseResolution(tt.resolution); w != tt.width || h != tt.height {
+			t.Errorf("parseResolution(%q) = %d, %d, want %d, %d", tt.resolution, w, h, tt.width, tt.height)
+		}
+	}
+}
+
+func TestVariantFilterChoose(t *testing.T) {
+	t.Parallel()
+
+	variants := []*m3u8.Variant{
+		{URI: "v360", VariantParams: m3u8.VariantParams{Bandwidth: 800000, Resolution: "640x360", Codecs: "avc1.4d401e,mp4a.40.2"}},
+		{URI: "v720", VariantParams: m3u8.VariantParams{Bandwidth: 2500000, Resolution: "1280x720", Codecs: "avc1.64001f,mp4a.40.2"}},
+		{URI: "v1080", VariantParams: m3u8.VariantParams{Bandwidth: 4000000, Resolution: "1920x1080", Codecs: "hvc1.1.6.L120,mp4a.40.2"}},
+		{URI: "noresolution", VariantParams: m3u8.VariantParams{Bandwidth: 100000, Codecs: "av01.0.04M.08"}},
+		{URI: "iframes", VariantParams: m3u8.VariantParams{Bandwidth: 9000000, Resolution: "1920x1080", Iframe: true}},
+		nil,
+	}
+	tests := []struct {
+		name    string
+		filter  VariantFilter
+		want    string
+		wantErr bool
+	}{
+		{"highest bandwidth", VariantFilter{}, "v1080", false},
+		{"max height", VariantFilter{MaxHeight: 720}, "v720", false},
+		{"max bandwidth", VariantFilter{MaxBandwidth: 1000000}, "v360", false},
+		{"h264 alias", VariantFilter{Codec: "h264"}, "v720", false},
+		{"hevc alias", VariantFilter{Codec: "HEVC"}, "v1080", false},
+		{"av1 alias", VariantFilter{Codec: "av1"}, "noresolution", false},
+		{"raw codec prefix", VariantFilter{Codec: "avc1.4d"}, "v360", false},
+		{"audio codec", VariantFilter{Codec: "aac", MaxHeight: 360}, "v360", false},
+		{"height and codec", VariantFilter{MaxHeight: 1080, Codec: "avc"}, "v720", false},
+		{"nothing matches", VariantFilter{MaxBandwidth: 50000}, "", true},
+		{"unknown codec", VariantFilter{Codec: "vp9"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := tt.filter.choose(variants)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "1280x720 2500000") {
+					t.Errorf("choose() = %v, %v, want an error listing the variants", got, err)
+				}
+				return
+			}
+			if err != nil || got.URI != tt.want {
+				t.Errorf("choose() = %v, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := (VariantFilter{}).choose(nil); err == nil {
+		t.Error("choose() of no variants error = nil")
+	}
+}
+
+// newMasterServer serves masterFixture with two-segment media playlists
+// whose segments echo their path, and counts the segment requests.
+func newMasterServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	var segments atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/master.m3u8":
+			fmt.Fprint(w, masterFixture)
+		case strings.HasSuffix(req.URL.Path, ".m3u8"):
+			name := strings.TrimSuffix(filepath.Base(req.URL.Path), ".m3u8")
+			fmt.Fprintf(w, "#EXTM3U\n#EXT-X-TARGETDURATION:5\n#EXTINF:5,\n%s-0.ts\n#EXTINF:5,\n%s-1.ts\n#EXT-X-ENDLIST\n", name, name)
+		default:
+			segments.Add(1)
+			fmt.Fprintf(w, "<%s>", req.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &segments
+}
+
+func TestInspect(t *testing.T) {
+	t.Parallel()
+
+	srv, _ := newMasterServer(t)
+	info, err := NewHLSDownloader(srv.Client()).Inspect([]string{srv.URL + "/master.m3u8"})
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	if len(info.Variants) != 3 {
+		t.Errorf("Inspect() variants = %+v, want 3 without the I-frame stream", info.Variants)
+	}
+	if v := info.Variants[1]; v.Width != 1280 || v.Height != 720 || v.FrameRate != 25 || v.AudioGroup != "aud" {
+		t.Errorf("Inspect() 720p variant = %+v", v)
+	}
+	// Every variant repeats the renditions of its group
+	if len(info.Renditions) != 2 || info.Renditions[0].Language != "ru" || info.Renditions[1].URI != "audio/en.m3u8" {
+		t.Errorf("Inspect() renditions = %+v, want ru and en once", info.Renditions)
+	}
+
+	info, err = NewHLSDownloader(srv.Client()).Inspect([]string{srv.URL + "/v720.m3u8"})
+	if err != nil || len(info.Variants) != 0 || len(info.Renditions) != 0 {
+		t.Errorf("Inspect() of a media playlist = %+v, %v, want nothing", info, err)
+	}
+}
+
+func TestDownloadAudio(t *testing.T) {
+	t.Parallel()
+
+	srv, segments := newMasterServer(t)
+	output := filepath.Join(t.TempDir(), "movie.ts")
+	d := NewHLSDownloader(srv.Client())
+	d.SetVariantFilter(VariantFilter{MaxHeight: 720})
+
+	// The muxed ru rendition has nothing to save
+	saved, err := d.DownloadAudio([]string{srv.URL + "/master.m3u8"}, []string{"EN", " ru"}, output)
+	want := filepath.Join(filepath.Dir(output), "movie.en.audio.ts")
+	if err != nil || len(saved) != 1 || saved[0] != want {
+		t.Fatalf("DownloadAudio() = %v, %v, want [%s]", saved, err, want)
+	}
+	if got, _ := os.ReadFile(want); string(got) != "</audio/en-0.ts></audio/en-1.ts>" {
+		t.Errorf("DownloadAudio() wrote %q", got)
+	}
+
+	// A complete rendition is skipped, but still reported
+	segments.Store(0)
+	saved, err = d.DownloadAudio([]string{srv.URL + "/master.m3u8"}, []string{"all"}, output)
+	if err != nil || len(saved) != 1 || segments.Load() != 0 {
+		t.Errorf("second DownloadAudio() = %v, %v after %d segments, want no segment fetched", saved, err, segments.Load())
+	}
+
+	d.SetOverwrite(true)
+	if _, err := d.DownloadAudio([]string{srv.URL + "/master.m3u8"}, []string{"en"}, output); err != nil || segments.Load() != 2 {
+		t.Errorf("DownloadAudio() with overwrite = %v after %d segments, want 2", err, segments.Load())
+	}
+}
@@ -1,56 +1,192 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"runtime"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/alexflint/go-arg"
 	expandrange "github.com/n0madic/expand-range"
 	"github.com/n0madic/go-hdrezka"
+	"github.com/n0madic/go-hdrezka/nfo"
+	"github.com/n0madic/go-hdrezka/subtitles"
 )
 
 var args struct {
-	URL         string `arg:"positional,required" help:"url for download video"`
-	Output      string `arg:"positional" help:"output file or path for downloaded video"`
-	BaseURL     string `arg:"-b,--base-url" placeholder:"URL" help:"base URL of hdrezka site (e.g., https://hdrezka.ag)"`
-	Info        bool   `arg:"-i" help:"show info about video only"`
-	MaxAttempt  int    `arg:"-m,--max-attempt" placeholder:"INT" default:"3" help:"max attempts for download file"`
-	Overwrite   bool   `arg:"-o,--overwrite" help:"overwrite output file if exists"`
-	Quality     string `arg:"-q,--quality" default:"1080p" help:"quality for download video"`
-	Season      string `arg:"-s,--season" placeholder:"RANGE" help:"season or range of seasons to download (e.g. 1, 2-3, 1,3,5)"`
-	Episodes    string `arg:"-e,--episodes" placeholder:"RANGE" help:"range of episodes to download, requires single --season (e.g. 1, 3-5, 1,3,7-9)"`
-	Translation string `arg:"-t,--translation" placeholder:"NAME" help:"translation for download video"`
-	Subtitle    string `arg:"-c,--subtitle" placeholder:"LANG" help:"get subtitle for downloaded video"`
-	Resolver    string `arg:"-r,--resolver" placeholder:"IP" help:"DNS resolver for download video"`
-	Proxy       string `arg:"-p,--proxy" placeholder:"URL" help:"proxy for download video"`
-	UseHLS      bool   `arg:"-l,--hls" help:"use HLS instead of MP4 for download video"`
-	Login       string `arg:"--login" placeholder:"NAME" help:"hdrezka account login (email or username), requires --password"`
-	Password    string `arg:"--password" placeholder:"PASS" help:"hdrezka account password, requires --login"`
-	Cookies     string `arg:"--cookies" placeholder:"STRING" help:"raw cookies string, e.g. \"dle_user_id=123;dle_password=abc\""`
+	URL             string `arg:"positional,required" help:"url for download video"`
+	Output          string `arg:"positional" help:"output file or path for downloaded video"`
+	BaseURL         string `arg:"-b,--base-url" placeholder:"URL" help:"base URL of hdrezka site (e.g., https://hdrezka.ag)"`
+	Info            bool   `arg:"-i" help:"show info about video only"`
+	ListFormats     bool   `arg:"-F,--list-formats" help:"list qualities with size, bitrate, resolution and duration, then exit"`
+	MaxAttempt      int    `arg:"-m,--max-attempt" placeholder:"INT" default:"3" help:"max attempts for download file"`
+	Overwrite       bool   `arg:"-o,--overwrite" help:"overwrite output file if exists"`
+	Quality         string `arg:"-q,--quality" default:"1080p" help:"quality for download video: best, worst, <=720p or a preference list like 1080p,720p; falls back to worst when capped with <=, else to best"`
+	Season          string `arg:"-s,--season" placeholder:"RANGE" help:"season or range of seasons to download (e.g. 1, 2-3, 1,3,5)"`
+	Episodes        string `arg:"-e,--episodes" placeholder:"RANGE" help:"range of episodes to download, requires single --season (e.g. 1, 3-5, 1,3,7-9)"`
+	Translation     string `arg:"-t,--translation" placeholder:"NAME" help:"translation for download video"`
+	Version         string `arg:"-v,--version" placeholder:"VERSION" help:"movie version to download: director or theatrical"`
+	Subtitle        string `arg:"-c,--subtitle" placeholder:"LANG" help:"get subtitle for downloaded video by label or language code, or \"all\" for every language"`
+	SubFormat       string `arg:"--subtitle-format" placeholder:"FORMAT" default:"vtt" help:"subtitle file format (srt|vtt|ass)"`
+	Trailer         bool   `arg:"--trailer" help:"also download the trailer next to the video file"`
+	OutputTemplate  string `arg:"-O,--output-template" placeholder:"TEMPLATE" help:"Go template for output paths with fields .Title, .TitleOriginal, .Name, .Year, .Season, .Episode, .EpisodeTitle, .Translation, .Quality and .Ext; directories are created"`
+	WriteNFO        bool   `arg:"--write-nfo" help:"write Kodi/Jellyfin NFO metadata and the cover as a poster next to the video"`
+	Resolver        string `arg:"-r,--resolver" placeholder:"IP" help:"DNS resolver for download video"`
+	Proxy           string `arg:"-p,--proxy" placeholder:"URL" help:"proxy for download video"`
+	UseHLS          bool   `arg:"-l,--hls" help:"use HLS instead of MP4 for download video"`
+	HLSJobs         int    `arg:"--hls-jobs" placeholder:"INT" default:"4" help:"number of HLS segments downloaded at once"`
+	HLSResolution   int    `arg:"--hls-resolution" placeholder:"HEIGHT" help:"pick the best HLS variant no taller than HEIGHT, e.g. 720"`
+	HLSMaxBandwidth uint32 `arg:"--hls-max-bandwidth" placeholder:"BPS" help:"pick the best HLS variant within this bandwidth in bits per second"`
+	HLSCodec        string `arg:"--hls-codec" placeholder:"CODEC" help:"pick an HLS variant using this codec, e.g. avc1, h264 or hevc"`
+	HLSAudio        string `arg:"--hls-audio" placeholder:"LANGS" help:"also save alternate HLS audio renditions of these languages or names, or \"all\", as separate files"`
+	ListVariants    bool   `arg:"--list-variants" help:"list HLS variants and renditions of the selected quality, then exit"`
+	Login           string `arg:"--login" placeholder:"NAME" help:"hdrezka account login (email or username), requires --password"`
+	Password        string `arg:"--password" placeholder:"PASS" help:"hdrezka account password, requires --login"`
+	Cookies         string `arg:"--cookies" placeholder:"STRING" help:"raw cookies string, e.g. \"dle_user_id=123;dle_password=abc\""`
 }
 
+// sanitizeFilename makes a template value safe as a file name on every
+// filesystem: path separators become dashes, other characters invalid on
+// Windows become spaces, and leading or trailing spaces and dots go.
 func sanitizeFilename(filename string) string {
-	if runtime.GOOS == "windows" {
-		// Replace invalid characters for Windows filesystem with spaces
-		invalidChars := []string{"<", ">", ":", "\"", "/", "\\", "|", "?", "*"}
-		result := filename
-		for _, char := range invalidChars {
-			result = strings.ReplaceAll(result, char, " ")
+	result := strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\':
+			return '-'
+		case r < ' ' || strings.ContainsRune(`<>:"|?*`, r):
+			return ' '
 		}
+		return r
+	}, filename)
 
-		// Clean up consecutive spaces
-		for strings.Contains(result, "  ") {
-			result = strings.ReplaceAll(result, "  ", " ")
+	// Clean up consecutive spaces
+	result = strings.Join(strings.Fields(result), " ")
+
+	return strings.Trim(result, " .")
+}
+
+// matchTranslation reports whether tr is the translation requested by name.
+// Besides the plain name it accepts the "Name (uk)" form printed in the
+// video info and the "Name UA" form older versions used for Ukrainian.
+func matchTranslation(tr *hdrezka.Translation, name string) bool {
+	switch name {
+	case tr.Name, tr.Name + " (" + string(tr.Language) + ")":
+		return true
+	case tr.Name + " UA":
+		return tr.Language == "uk"
+	}
+	return false
+}
+
+// printFormats probes every quality of stream and prints them as a table.
+func printFormats(stream *hdrezka.Stream) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "QUALITY\tRESOLUTION\tDURATION\tSIZE\tBITRATE\tNOTE")
+	for _, probe := range stream.Probe() {
+		resolution, duration, size, bitrate, note := "-", "-", "-", "-", ""
+		if probe.Width > 0 {
+			resolution = fmt.Sprintf("%dx%d", probe.Width, probe.Height)
+		} else if probe.Height > 0 {
+			resolution = fmt.Sprintf("%dp", probe.Height)
+		}
+		if probe.Duration > 0 {
+			duration = probe.Duration.Round(time.Second).String()
+		}
+		if probe.Size > 0 {
+			size = formatSize(probe.Size)
 		}
+		if probe.Bitrate > 0 {
+			bitrate = fmt.Sprintf("%d kbit/s", probe.Bitrate/1000)
+		}
+		if probe.Err != nil {
+			note = "unavailable"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", probe.Quality, resolution, duration, size, bitrate, note)
+	}
+	w.Flush()
+}
 
-		// Trim spaces from beginning and end
-		result = strings.TrimSpace(result)
-		return result
+// printVariants lists the HLS variants and renditions of the --quality
+// format of stream.
+func printVariants(stream *hdrezka.Stream) error {
+	quality, format, err := stream.Pick(args.Quality)
+	if err != nil {
+		return err
+	}
+	if len(format.HLSURLs) == 0 {
+		return fmt.Errorf("HLS stream not available for quality %s", quality)
+	}
+	info, err := NewHLSDownloader(siteClient).Inspect(format.HLSURLs)
+	if err != nil {
+		return err
 	}
-	return filename
+	if len(info.Variants) == 0 {
+		fmt.Printf("HLS stream of %s has a single media playlist\n", quality)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RESOLUTION\tBANDWIDTH\tFPS\tCODECS\tAUDIO")
+	for _, variant := range info.Variants {
+		resolution, fps := "-", "-"
+		if variant.Height > 0 {
+			resolution = fmt.Sprintf("%dx%d", variant.Width, variant.Height)
+		}
+		if variant.FrameRate > 0 {
+			fps = strconv.FormatFloat(variant.FrameRate, 'f', -1, 64)
+		}
+		fmt.Fprintf(w, "%s\t%d kbit/s\t%s\t%s\t%s\n", resolution, variant.Bandwidth/1000, fps, variant.Codecs, variant.AudioGroup)
+	}
+	w.Flush()
+
+	if len(info.Renditions) > 0 {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TYPE\tGROUP\tLANGUAGE\tNAME\tNOTE")
+		for _, rendition := range info.Renditions {
+			var notes []string
+			if rendition.Default {
+				notes = append(notes, "default")
+			}
+			if rendition.URI == "" {
+				notes = append(notes, "muxed")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", rendition.Type, rendition.GroupID, rendition.Language, rendition.Name, strings.Join(notes, ", "))
+		}
+		w.Flush()
+	}
+	return nil
+}
+
+// formatSize formats a byte count with a binary unit, e.g. "1.4 GiB".
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// qualityFallback is the spec used when no --quality preference is
+// available: the lowest quality, the nearest one, when spec caps it with
+// "<=", otherwise the best.
+func qualityFallback(spec string) string {
+	for _, pref := range strings.Split(spec, ",") {
+		if strings.HasPrefix(strings.TrimSpace(pref), "<=") {
+			return "worst"
+		}
+	}
+	return "best"
 }
 
 func main() {
@@ -67,6 +203,25 @@ func main() {
 		}
 	}
 
+	var version hdrezka.Variant
+	if args.Version != "" {
+		var err error
+		if version, err = hdrezka.ParseVariant(args.Version); err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := hdrezka.CheckQualitySpec(args.Quality); err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+
+	if _, err := subtitles.ParseFormat(args.SubFormat); err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+
 	if (args.Login != "") != (args.Password != "") {
 		fmt.Println("error: --login and --password must be used together")
 		os.Exit(1)
@@ -147,102 +302,251 @@ func main() {
 		args.Output = ""
 	}
 
-	if args.Output == "" {
-		title := video.Title
-		if video.TitleOriginal != "" {
-			title = video.TitleOriginal
-		}
-		title = strings.ReplaceAll(title, "/", "-")
-		ext := ".mp4"
-		if args.UseHLS {
-			ext = ".ts"
-		}
-		args.Output = sanitizeFilename(fmt.Sprintf("%s (%s)%s", title, video.Year, ext))
+	if args.Output != "" && args.OutputTemplate != "" {
+		fmt.Println("error: OUTPUT must be a directory when --output-template is set")
+		os.Exit(1)
 	}
 
 	var translation *hdrezka.Translation
 	for _, tr := range video.Translation {
-		if args.Translation != "" && tr.Name == args.Translation {
+		if args.Translation != "" && matchTranslation(tr, args.Translation) {
 			translation = tr
 			break
-		} else if tr.IsDefault {
+		} else if args.Translation == "" && tr.IsDefault {
 			translation = tr
 		}
 	}
-	if args.Translation != "" && translation.Name != args.Translation {
+	if translation == nil {
 		fmt.Printf("Translation %s not found\n", args.Translation)
 		os.Exit(4)
 	}
+	if args.Version != "" {
+		if !slices.Contains(translation.Variants, version) {
+			fmt.Printf("Version %s not available for translation %s, available: %v\n", version, translation.Name, translation.Variants)
+			os.Exit(4)
+		}
+		translation = translation.WithVariant(version)
+	}
 
-	downloadStream := func(season int, episode int) {
-		output := args.Output
-		if season > 0 {
-			output = sanitizeFilename(fmt.Sprintf("s%02de%02d %s", season, episode, output))
+	ext := "mp4"
+	if args.UseHLS {
+		ext = "ts"
+	}
+	namer, err := newOutputNamer(args.OutputTemplate, args.Output, video, translation.Name, ext)
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	movieOutput, err := namer.render(0, 0, "")
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+
+	// wanted reports whether an episode is selected by --season and --episodes
+	wanted := func(season, episode int) bool {
+		return (len(seasonRange) == 0 || seasonRange.InRange(uint64(season))) &&
+			(args.Episodes == "" || epRange.InRange(uint64(episode)))
+	}
+
+	if args.ListFormats || args.ListVariants {
+		var stream *hdrezka.Stream
+		// Probe the first episode the other flags select
+		for es, err := range translation.Streams(context.Background(), hdrezka.StreamFilter{Include: wanted}) {
+			if es == nil {
+				// Not a series
+				stream, err = translation.GetStream()
+			} else {
+				fmt.Printf("Formats of season %d episode %d:\n", es.Season, es.Episode)
+				stream = es.Stream
+			}
+			if err != nil {
+				fmt.Println("error:", err)
+				os.Exit(5)
+			}
+			break
 		}
-		fileInfo, err := os.Stat(output)
-		if !args.Overwrite && err == nil && fileInfo.Size() > 0 {
-			fmt.Printf("File %s already exists, skipping\n", output)
+		if stream == nil {
+			fmt.Println("error: no episode matches --season/--episodes")
+			os.Exit(5)
+		}
+		if args.ListVariants {
+			if err := printVariants(stream); err != nil {
+				fmt.Println("error:", err)
+				os.Exit(5)
+			}
 			return
 		}
-		stream, err := translation.GetStream(season, episode)
+		printFormats(stream)
+		return
+	}
+
+	// Series extras go with the show; those of a movie are named after
+	// its file, known once the quality is picked
+	if args.WriteNFO || args.Trailer {
+		if episodes, _ := translation.GetEpisodes(); len(episodes) > 0 {
+			if args.WriteNFO {
+				writeVideoNFO(video, true, true, namer.showDir(), "")
+			}
+			if args.Trailer {
+				downloadTrailer(video, movieOutput)
+			}
+		}
+	}
+	movieExtras := func(output string) {
+		if args.WriteNFO {
+			writeVideoNFO(video, false, namer.movieHasOwnDir(), filepath.Dir(output), output)
+		}
+		if args.Trailer {
+			downloadTrailer(video, output)
+		}
+	}
+	episodeNFO := func(season, episode int, output string) {
+		if !args.WriteNFO {
+			return
+		}
+		nfoOutput := strings.TrimSuffix(output, filepath.Ext(output)) + ".nfo"
+		if err := writeNFO(nfoOutput, nfo.NewEpisode(video, season, episode)); err != nil {
+			fmt.Printf("ERROR %s: %s\n", nfoOutput, err)
+		}
+	}
+
+	exists := func(output string) bool {
+		fileInfo, err := os.Stat(output)
+		if args.Overwrite || err != nil || fileInfo.Size() == 0 {
+			return false
+		}
+		// An HLS file is only done once its sidecar says so; partial
+		// files are resumed. Audio renditions are checked on their own
+		// once the master playlist is known.
+		if args.UseHLS && (!hlsComplete(output) || args.HLSAudio != "") {
+			return false
+		}
+		fmt.Printf("File %s already exists, skipping\n", output)
+		return true
+	}
+	// Output paths naming the quality can only be checked once the
+	// stream is resolved
+	existsBeforeStream := func(season, episode int) bool {
+		output, err := namer.render(season, episode, "")
+		return err == nil && !namer.usesQuality() && exists(output)
+	}
+
+	// downloadStream saves stream as the movie, or as an episode when
+	// season is set, and returns the output path, also when the file
+	// already exists, or "" when it failed
+	downloadStream := func(season, episode int, stream *hdrezka.Stream) string {
+		name := "movie"
+		if season > 0 {
+			name = fmt.Sprintf("season %d episode %d", season, episode)
+		}
+		quality, format, err := stream.Pick(args.Quality)
 		if err != nil {
+			quality, format, err = stream.Pick(qualityFallback(args.Quality))
+			if err != nil {
+				fmt.Printf("ERROR %s: %s\n", name, err)
+				return ""
+			}
+			fmt.Printf("Quality %s not available for %s, using %s\n", args.Quality, name, quality)
+		}
+
+		output, err := namer.render(season, episode, quality.Label)
+		if err != nil {
+			fmt.Printf("ERROR %s: %s\n", name, err)
+			return ""
+		}
+		if namer.usesQuality() && exists(output) {
+			return output
+		}
+		if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
 			fmt.Printf("ERROR %s: %s\n", output, err)
-			return
+			return ""
 		}
-		format, ok := stream.Formats[args.Quality]
-		if !ok {
-			fmt.Printf("ERROR %s: quality %s not found\n", output, args.Quality)
-			return
+
+		// Signed links expire; fetch the same quality again when the CDN
+		// starts rejecting them
+		refresh := func(ctx context.Context) ([]string, error) {
+			if err := stream.RefreshContext(ctx); err != nil {
+				return nil, err
+			}
+			format, found := stream.Formats[quality.Label]
+			if !found {
+				return nil, fmt.Errorf("quality %s not available after refresh", quality)
+			}
+			if args.UseHLS {
+				return format.HLSURLs, nil
+			}
+			return format.MP4URLs, nil
 		}
 
 		// Download using HLS or MP4 based on user choice
 		if args.UseHLS {
 			// Use HLS stream
-			if format.HLS == "" {
-				fmt.Printf("ERROR %s: HLS stream not available for quality %s\n", output, args.Quality)
-				return
+			if len(format.HLSURLs) == 0 {
+				fmt.Printf("ERROR %s: HLS stream not available for quality %s\n", output, quality)
+				return ""
 			}
-			err = downloadHLSPlaylist(format.HLS, output)
+			err = downloadHLSPlaylist(format.HLSURLs, refresh, output)
 		} else {
 			// Use MP4 stream
-			err = downloadFile(format.MP4, output, args.MaxAttempt)
+			err = downloadFile(format.MP4URLs, refresh, output, minMediaSize, args.MaxAttempt)
 		}
 
 		if err != nil {
 			fmt.Printf("ERROR %s: %s\n", output, err)
-			return
+			return ""
 		}
 
 		// Download subtitles if requested
 		if args.Subtitle != "" {
-			subtitle, ok := stream.Subtitles[args.Subtitle]
-			if !ok {
-				fmt.Printf("ERROR %s: subtitle %s not found\n", output, args.Subtitle)
-				return
-			}
-			outputSub := output[:strings.LastIndex(output, ".")] + ".vtt"
-			err = downloadFile(subtitle, outputSub, args.MaxAttempt)
-			if err != nil {
-				fmt.Printf("ERROR %s: %s\n", outputSub, err)
-				return
+			if err := downloadSubtitles(stream, output); err != nil {
+				fmt.Printf("ERROR %s: %s\n", output, err)
+				return ""
 			}
 		}
+		return output
 	}
 
-	episodes, err := translation.GetEpisodes()
-	if err == nil {
-		for _, season := range episodes.ListSeasons() {
-			if len(seasonRange) > 0 && !seasonRange.InRange(uint64(season)) {
-				continue
+	// Resolve the next episode's stream while the current one downloads
+	filter := hdrezka.StreamFilter{
+		Include: func(season, episode int) bool {
+			if !wanted(season, episode) {
+				return false
 			}
-			for _, episode := range episodes.ListEpisodes(season) {
-				if args.Episodes != "" && !epRange.InRange(uint64(episode)) {
-					continue
+			if existsBeforeStream(season, episode) {
+				// Skipped episodes of an existing library get their NFO too
+				if output, err := namer.render(season, episode, ""); err == nil {
+					episodeNFO(season, episode, output)
 				}
-				downloadStream(season, episode)
+				return false
 			}
+			return true
+		},
+		Jobs: 2,
+	}
+	for es, err := range translation.Streams(context.Background(), filter) {
+		if es == nil {
+			// No episode list: a movie
+			if existsBeforeStream(0, 0) {
+				movieExtras(movieOutput)
+				return
+			}
+			stream, err := translation.GetStream()
+			if err != nil {
+				fmt.Printf("ERROR %s: %s\n", movieOutput, err)
+				return
+			}
+			if output := downloadStream(0, 0, stream); output != "" {
+				movieExtras(output)
+			}
+			return
+		}
+		if err != nil {
+			fmt.Printf("ERROR season %d episode %d: %s\n", es.Season, es.Episode, err)
+			continue
+		}
+		if output := downloadStream(es.Season, es.Episode, es.Stream); output != "" {
+			episodeNFO(es.Season, es.Episode, output)
 		}
-	} else {
-		downloadStream(0, 0)
 	}
 }
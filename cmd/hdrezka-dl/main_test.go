@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestQualityFallback(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"1080p", "best"},
+		{"1080p Ultra,720p", "best"},
+		{"<=480p", "worst"},
+		{"1080p, <=720p", "worst"},
+	}
+	for _, tt := range tests {
+		if got := qualityFallback(tt.spec); got != tt.want {
+			t.Errorf("qualityFallback(%q) = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+}
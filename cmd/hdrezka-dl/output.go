@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/n0madic/go-hdrezka"
+)
+
+// Default output names, "Title (Year).mp4" and "s01e02 Title (Year).mp4".
+const (
+	defaultMovieTemplate  = `{{.Name}} ({{.Year}}).{{.Ext}}`
+	defaultSeriesTemplate = `s{{printf "%02d" .Season}}e{{printf "%02d" .Episode}} {{.Name}} ({{.Year}}).{{.Ext}}`
+)
+
+// outputFields are the values available to --output-template. Season and
+// Episode are zero for movies.
+type outputFields struct {
+	Title         string
+	TitleOriginal string
+	// Name is TitleOriginal when the video has one, else Title.
+	Name         string
+	Year         string
+	Season       int
+	Episode      int
+	EpisodeTitle string
+	Translation  string
+	Quality      string
+	Ext          string
+}
+
+// outputNamer renders the output paths of the episodes or the movie being
+// downloaded.
+type outputNamer struct {
+	movie, series *template.Template
+	video         *hdrezka.Video
+	translation   string
+	ext           string
+}
+
+// newOutputNamer uses pattern for both movies and series, or the default
+// names when it is empty. A fixed output file is used as is for a movie and
+// with an "s01e02 " prefix on the file name for episodes.
+func newOutputNamer(pattern, output string, video *hdrezka.Video, translation, ext string) (*outputNamer, error) {
+	movie, series := pattern, pattern
+	switch {
+	case output != "":
+		movie = "{{" + strconv.Quote(output) + "}}"
+		dir, file := filepath.Split(output)
+		series = "{{" + strconv.Quote(dir) + `}}s{{printf "%02d" .Season}}e{{printf "%02d" .Episode}} {{` + strconv.Quote(file) + "}}"
+	case pattern == "":
+		movie, series = defaultMovieTemplate, defaultSeriesTemplate
+	}
+	n := &outputNamer{video: video, translation: translation, ext: ext}
+	var err error
+	if n.movie, err = template.New("output").Parse(movie); err != nil {
+		return nil, fmt.Errorf("invalid output template: %w", err)
+	}
+	if n.series, err = template.New("output").Parse(series); err != nil {
+		return nil, fmt.Errorf("invalid output template: %w", err)
+	}
+	// Catch unknown fields before anything is downloaded
+	if _, err := n.render(1, 1, ""); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// render returns the output path of an episode, or of the movie when season
+// is zero. Every value is sanitized, so only the template itself can add
+// directories.
+func (n *outputNamer) render(season, episode int, quality string) (string, error) {
+	name := n.video.Title
+	if n.video.TitleOriginal != "" {
+		name = n.video.TitleOriginal
+	}
+	fields := outputFields{
+		Title:         sanitizeFilename(n.video.Title),
+		TitleOriginal: sanitizeFilename(n.video.TitleOriginal),
+		Name:          sanitizeFilename(name),
+		Year:          sanitizeFilename(n.video.Year),
+		Season:        season,
+		Episode:       episode,
+		Translation:   sanitizeFilename(n.translation),
+		Quality:       sanitizeFilename(quality),
+		Ext:           n.ext,
+	}
+	if item, found := n.video.ScheduledEpisode(season, episode); found {
+		fields.EpisodeTitle = sanitizeFilename(item.Title)
+	}
+	tmpl := n.movie
+	if season > 0 {
+		tmpl = n.series
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, fields); err != nil {
+		return "", fmt.Errorf("invalid output template: %w", err)
+	}
+	output := strings.TrimSpace(buf.String())
+	if output == "" || strings.HasSuffix(output, "/") || strings.HasSuffix(output, string(filepath.Separator)) {
+		return "", fmt.Errorf("output template gives no file name for season %d episode %d", season, episode)
+	}
+	return filepath.Clean(output), nil
+}
+
+// usesQuality reports whether output paths depend on the quality, which is
+// only known once the stream is resolved.
+func (n *outputNamer) usesQuality() bool {
+	a, _ := n.render(1, 1, "360p")
+	b, _ := n.render(1, 1, "1080p")
+	return a != b
+}
+
+// movieHasOwnDir reports whether the movie template puts every movie in a
+// directory of its own, where movie.nfo and poster.jpg cannot clash.
+func (n *outputNamer) movieHasOwnDir() bool {
+	dir := func(title, year string) string {
+		other := *n
+		other.video = &hdrezka.Video{Title: title, Year: year}
+		output, _ := other.render(0, 0, "")
+		return filepath.Dir(output)
+	}
+	return dir("a", "2001") != dir("b", "2002")
+}
+
+// showDir is the deepest directory shared by the episodes of different
+// seasons and qualities, where tvshow.nfo and the poster go.
+func (n *outputNamer) showDir() string {
+	a, _ := n.render(1, 1, "360p")
+	b, _ := n.render(2, 2, "1080p")
+	dirA := strings.Split(filepath.Dir(a), string(filepath.Separator))
+	dirB := strings.Split(filepath.Dir(b), string(filepath.Separator))
+	var common []string
+	for i := 0; i < len(dirA) && i < len(dirB) && dirA[i] == dirB[i]; i++ {
+		common = append(common, dirA[i])
+	}
+	if len(common) == 0 {
+		return "."
+	}
+	if common[0] == "" {
+		// Absolute path
+		return string(filepath.Separator) + filepath.Join(common[1:]...)
+	}
+	return filepath.Join(common...)
+}
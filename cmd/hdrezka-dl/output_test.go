@@ -0,0 +1,153 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/n0madic/go-hdrezka"
+)
+
+func testVideo() *hdrezka.Video {
+	return &hdrezka.Video{
+		Title:         "Во все тяжкие",
+		TitleOriginal: "Breaking Bad",
+		Year:          "2008",
+		Schedule:      []hdrezka.ScheduleItem{{Season: 1, Episode: 2, Title: "Cat's in the Bag: Part 1/2"}},
+	}
+}
+
+func TestOutputNamerRender(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		pattern  string
+		output   string
+		season   int
+		episode  int
+		quality  string
+		want     string
+		wantErr  bool
+		fallback bool // newOutputNamer itself fails
+	}{
+		{name: "default movie", want: "Breaking Bad (2008).mp4"},
+		{name: "default episode", season: 1, episode: 2, want: "s01e02 Breaking Bad (2008).mp4"},
+		{name: "fixed movie", output: "out/Show.mp4", want: "out/Show.mp4"},
+		{name: "fixed episode keeps the directory", output: "out/Show.mp4", season: 1, episode: 2, want: "out/s01e02 Show.mp4"},
+		{name: "fixed absolute episode", output: "/media/tv/Show.mp4", season: 3, episode: 10, want: "/media/tv/s03e10 Show.mp4"},
+		{
+			name:    "nested template with sanitized values",
+			pattern: `{{.Name}}/Season {{printf "%02d" .Season}}/{{.EpisodeTitle}} [{{.Quality}}].{{.Ext}}`,
+			season:  1, episode: 2, quality: "1080p Ultra",
+			want: "Breaking Bad/Season 01/Cat's in the Bag Part 1-2 [1080p Ultra].mp4",
+		},
+		{
+			name:    "absolute template",
+			pattern: `/media/{{.Title}} ({{.Year}})/{{.Translation}}.{{.Ext}}`,
+			want:    "/media/Во все тяжкие (2008)/Дубляж.mp4",
+		},
+		{name: "template without a file name", pattern: `{{.Name}}/`, wantErr: true},
+		{name: "unknown field", pattern: `{{.Nope}}`, fallback: true},
+		{name: "invalid template", pattern: `{{.Name`, fallback: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			namer, err := newOutputNamer(tt.pattern, tt.output, testVideo(), "Дубляж", "mp4")
+			if tt.fallback {
+				if err == nil {
+					t.Errorf("newOutputNamer(%q) error = nil", tt.pattern)
+				}
+				return
+			}
+			if err != nil {
+				if !tt.wantErr {
+					t.Fatalf("newOutputNamer() error = %v", err)
+				}
+				return
+			}
+			got, err := namer.render(tt.season, tt.episode, tt.quality)
+			if (err != nil) != tt.wantErr || got != filepath.FromSlash(tt.want) {
+				t.Errorf("render(%d, %d, %q) = %q, %v, want %q", tt.season, tt.episode, tt.quality, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutputNamerDirs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		pattern     string
+		output      string
+		wantShowDir string
+		wantOwnDir  bool
+		wantQuality bool
+	}{
+		{name: "default", wantShowDir: "."},
+		{name: "fixed output", output: "out/Show.mp4", wantShowDir: "out"},
+		{
+			name:        "nested seasons",
+			pattern:     `{{.Name}}/Season {{.Season}}/{{.Name}} {{.Episode}}.{{.Ext}}`,
+			wantShowDir: "Breaking Bad",
+			wantOwnDir:  true,
+		},
+		{
+			name:        "absolute nested seasons",
+			pattern:     `/media/tv/{{.Name}}/S{{.Season}}/E{{.Episode}} {{.Quality}}.{{.Ext}}`,
+			wantShowDir: "/media/tv/Breaking Bad",
+			wantOwnDir:  true,
+			wantQuality: true,
+		},
+		{
+			name:        "flat directory",
+			pattern:     `/media/all/{{.Name}} {{.Season}}x{{.Episode}}.{{.Ext}}`,
+			wantShowDir: "/media/all",
+		},
+		{
+			name:        "quality directory",
+			pattern:     `{{.Quality}}/{{.Name}}.{{.Ext}}`,
+			wantShowDir: ".",
+			wantQuality: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			namer, err := newOutputNamer(tt.pattern, tt.output, testVideo(), "Дубляж", "mp4")
+			if err != nil {
+				t.Fatalf("newOutputNamer() error = %v", err)
+			}
+			if got := namer.showDir(); got != filepath.FromSlash(tt.wantShowDir) {
+				t.Errorf("showDir() = %q, want %q", got, tt.wantShowDir)
+			}
+			if got := namer.movieHasOwnDir(); got != tt.wantOwnDir {
+				t.Errorf("movieHasOwnDir() = %v, want %v", got, tt.wantOwnDir)
+			}
+			if got := namer.usesQuality(); got != tt.wantQuality {
+				t.Errorf("usesQuality() = %v, want %v", got, tt.wantQuality)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Breaking Bad", "Breaking Bad"},
+		{"AC/DC: Live", "AC-DC Live"},
+		{`a\b<c>d"e|f?g*h`, "a-b c d e f g h"},
+		{"  dots...  ", "dots"},
+		{"tab\there", "tab here"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeFilename(tt.name); got != tt.want {
+			t.Errorf("sanitizeFilename(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
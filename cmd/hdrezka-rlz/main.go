@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/alexflint/go-arg"
 	"github.com/n0madic/go-hdrezka"
+	"github.com/n0madic/go-hdrezka/feed"
 )
 
 type DefaultCmd struct{}
@@ -44,32 +48,74 @@ var args struct {
 	Search         *SearchCmd     `arg:"subcommand:search" help:"Search releases"`
 	Extended       bool           `arg:"-e,--extended" help:"Show extended info for release"`
 	Filter         hdrezka.Filter `arg:"-f,--filter" help:"Set filter for release (last|popular|watching)"`
+	Format         string         `arg:"--format" default:"text" help:"Output format for releases (text|rss|atom)"`
 	Genre          hdrezka.Genre  `arg:"-g,--genre" help:"Set genre for release (animation|cartoons|films|series|show)"`
-	ListCategories bool           `arg:"-l,--list-categories" help:"List categories of videos"`
+	Jobs           int            `arg:"-j,--jobs" default:"4" help:"number of releases fetched at once for --extended and of covers sized for rss"`
+	ListCategories bool           `arg:"-l,--list-categories" help:"List categories, countries and filters of videos"`
 	Mirrors        []string       `arg:"-m,--mirrors" help:"mirrors for hdrezka site"`
 	Number         int            `arg:"-n,--number" default:"36" help:"number of releases to show"`
+	RateLimit      time.Duration  `arg:"-r,--rate-limit" placeholder:"DURATION" default:"250ms" help:"minimum time between site requests"`
+}
+
+func printSorted(m map[string]string) {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Println("  ", k)
+	}
+}
+
+// feedTitle names the feed after the listing it was made from.
+func feedTitle(p *arg.Parser) string {
+	title := "HDrezka " + strings.Join(p.SubcommandNames(), " ")
+	switch {
+	case args.Category != nil:
+		title += ": " + args.Category.Category
+	case args.Country != nil:
+		title += ": " + args.Country.Country
+	case args.Year != nil:
+		title += ": " + args.Year.Year
+	case args.Search != nil:
+		title += ": " + args.Search.Query
+	}
+	return title
 }
 
 func main() {
-	arg.MustParse(&args)
+	p := arg.MustParse(&args)
+	if args.Format != "text" && args.Format != "rss" && args.Format != "atom" {
+		p.Fail("unknown format: " + args.Format)
+	}
 
-	r := hdrezka.New().WithMirrors(args.Mirrors...)
+	r := hdrezka.New().WithMirrors(args.Mirrors...).WithRateLimit(args.RateLimit)
 	if err := r.Init(); err != nil {
 		fmt.Printf("ERROR: %s\n", err)
 		os.Exit(1)
 	}
 
 	if args.ListCategories {
-		for genre, category := range r.Categories {
+		var genres []string
+		for genre := range r.Categories {
+			genres = append(genres, string(genre))
+		}
+		sort.Strings(genres)
+		for _, genre := range genres {
 			fmt.Println(genre + ":")
-			var categories []string
-			for c := range category {
-				categories = append(categories, c)
-			}
-			sort.Strings(categories)
-			for _, c := range categories {
-				fmt.Println("  ", c)
-			}
+			printSorted(r.Categories[hdrezka.Genre(genre)])
+		}
+		fmt.Println("countries:")
+		printSorted(r.Countries)
+		fmt.Println("filters:")
+		var filters []string
+		for filter := range r.Filters {
+			filters = append(filters, string(filter))
+		}
+		sort.Strings(filters)
+		for _, filter := range filters {
+			fmt.Printf("   %s (%s)\n", filter, r.Filters[hdrezka.Filter(filter)])
 		}
 		return
 	}
@@ -117,16 +163,40 @@ func main() {
 		os.Exit(2)
 	}
 
+	if args.Format != "text" {
+		f := &feed.Feed{
+			Title:       feedTitle(p),
+			Link:        r.URL.String(),
+			Description: fmt.Sprintf("Releases from %s", r.URL.Host),
+			Items:       feed.FromCovers(items),
+		}
+		if args.Format == "rss" {
+			feed.FetchImageLengths(context.Background(), r.Client, f.Items, args.Jobs)
+			err = f.WriteRSS(os.Stdout)
+		} else {
+			err = f.WriteAtom(os.Stdout)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var videos []hdrezka.VideoResult
+	if args.Extended {
+		urls := make([]string, len(items))
+		for i, item := range items {
+			urls[i] = item.URL
+		}
+		videos = r.GetVideos(context.Background(), urls, hdrezka.BulkOption{Jobs: args.Jobs})
+	}
+
 	fmt.Printf("List of releases (found %d):\n", len(items))
-	for _, item := range items {
+	for i, item := range items {
 		fmt.Println("--------------------------------------------------")
-		if args.Extended {
-			video, err := r.GetVideo(item.URL)
-			if err == nil {
-				fmt.Print(video)
-			} else {
-				fmt.Print(item)
-			}
+		if args.Extended && videos[i].Err == nil {
+			fmt.Print(videos[i].Video)
 		} else {
 			fmt.Print(item)
 		}
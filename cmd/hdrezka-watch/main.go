@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/alexflint/go-arg"
+	"github.com/n0madic/go-hdrezka"
+	"github.com/n0madic/go-hdrezka/watcher"
+)
+
+var args struct {
+	URLs      []string      `arg:"positional,required" placeholder:"URL" help:"urls of videos to watch"`
+	Interval  time.Duration `arg:"-i,--interval" default:"1h" help:"time between polls"`
+	Jobs      int           `arg:"-j,--jobs" default:"4" help:"number of videos fetched at once"`
+	Mirrors   []string      `arg:"-m,--mirrors" help:"mirrors for hdrezka site"`
+	Once      bool          `arg:"-o,--once" help:"poll once and exit"`
+	RateLimit time.Duration `arg:"-r,--rate-limit" placeholder:"DURATION" default:"250ms" help:"minimum time between site requests"`
+	Snapshot  string        `arg:"-s,--snapshot" placeholder:"FILE" default:"hdrezka-watch.json" help:"file the watched state is kept in between runs"`
+	Webhook   string        `arg:"-w,--webhook" placeholder:"URL" help:"also POST every event as JSON to this url"`
+}
+
+func main() {
+	arg.MustParse(&args)
+
+	r := hdrezka.New().WithMirrors(args.Mirrors...).WithRateLimit(args.RateLimit)
+	if err := r.Init(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
+
+	w := watcher.New(r, args.URLs, watcher.Options{
+		Interval:     args.Interval,
+		SnapshotPath: args.Snapshot,
+		Jobs:         args.Jobs,
+	}).Handle(watcher.JSONLines(os.Stdout))
+	if args.Webhook != "" {
+		w.Handle(watcher.Webhook(args.Webhook, nil))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	logError := func(err error) {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+	}
+	if args.Once {
+		if _, err := w.Check(ctx); err != nil {
+			logError(err)
+			os.Exit(1)
+		}
+		return
+	}
+	w.Run(ctx, logError)
+}
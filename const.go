@@ -15,6 +15,9 @@ const (
 	Show     Genre = "show"
 )
 
+// genres lists every concrete genre, in site navigation order.
+var genres = []Genre{Films, Series, Cartoons, Anime, Show}
+
 const (
 	FilterLast     Filter = "last"
 	FilterPopular  Filter = "popular"
@@ -30,6 +33,8 @@ const (
 	CoverNew
 )
 
+// categoriesShow is the fallback used when the "Передачи" menu can not be
+// discovered from the site navigation.
 var categoriesShow = map[string]string{
 	"Боевые искусства": "/show/fighting/",
 	"Детские":          "/show/kids/",
@@ -42,7 +47,7 @@ var categoriesShow = map[string]string{
 	"Познавательные":   "/show/cognitive/",
 	"Путешествия":      "/show/travel/",
 	"Реалити-шоу":      "/show/reality-shows/",
-	"Cпортивные":       "/show/sport/",
+	"Спортивные":       "/show/sport/",
 	"Семейные":         "/show/family/",
 	"Юмористические":   "/show/humor/",
 }
@@ -2,7 +2,9 @@ package hdrezka
 
 import (
 	"fmt"
+	"iter"
 	"net/url"
+	"slices"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
@@ -25,6 +27,26 @@ type CoverItem struct {
 	Info        string
 	Title       string
 	URL         string
+	// ID is the numeric video ID taken from the item URL.
+	ID string
+	// Type is the genre badge of the item (films, series, ...).
+	Type Genre
+	// Year, Countries and Categories are parsed from the "2023, США, Драмы"
+	// line under the title.
+	Year       int
+	Countries  []string
+	Categories []string
+	// Rating is only shown on some listings (best-of, quick search).
+	Rating Rating
+	// LatestSeason and LatestEpisode come from the series status badge
+	// ("2 сезон, 8 серия"); both are zero for films.
+	LatestSeason  int
+	LatestEpisode int
+	// Page is the listing page the item was found on and TotalPages the
+	// number of pages the listing reported at that moment. Both are zero for
+	// single-shot results such as QuickSearch and GetCoversNewest.
+	Page       int
+	TotalPages int
 }
 
 func (c *CoverItem) String() string {
@@ -36,6 +58,9 @@ func (c *CoverItem) String() string {
 	if c.Info != "" {
 		output += fmt.Sprintf("Info: %s\n", c.Info)
 	}
+	if c.Rating.Score > 0 {
+		output += fmt.Sprintf("Rating: %0.1f\n", c.Rating.Score)
+	}
 	if c.Cover != "" {
 		output += fmt.Sprintf("Cover: %s\n", c.Cover)
 	}
@@ -43,14 +68,20 @@ func (c *CoverItem) String() string {
 	return output
 }
 
-// GetCoversURL generate video URL by options.
+// GetCoversURL generate video URL by options. Genre, year, filter and
+// country values are checked against the catalog discovered by Init.
 func (r *HDRezka) GetCoversURL(opts CoverOption) (string, error) {
+	if err := r.checkCoverOption(opts); err != nil {
+		return "", err
+	}
+
 	uri := []string{"/"}
 	switch opts.Type {
 	case CoverByCategory:
 		uri = []string{"/" + string(opts.Genre) + "/"}
 	case CoverByCountry:
-		uri = []string{"/country/", opts.Country}
+		slug, _ := r.countrySlug(opts.Country)
+		uri = []string{"/country/", slug}
 	case CoverByYear:
 		uri = []string{"/year/", opts.Year}
 	case CoverNew:
@@ -60,7 +91,7 @@ func (r *HDRezka) GetCoversURL(opts CoverOption) (string, error) {
 	if (opts.Type == CoverByCategory || opts.Type == CoverBest) && opts.Category != "" {
 		cat, found := r.Categories[opts.Genre][opts.Category]
 		if !found {
-			return "", fmt.Errorf("category %s not found", opts.Category)
+			return "", fmt.Errorf("category %s not found, available: %s", opts.Category, joinKeys(r.Categories[opts.Genre]))
 		}
 		if opts.Type == CoverBest {
 			uri = strings.Split(cat, "/")
@@ -82,17 +113,8 @@ func (r *HDRezka) GetCoversURL(opts CoverOption) (string, error) {
 		q.Set("filter", string(opts.Filter))
 	}
 	if opts.Type == CoverByCountry || opts.Type == CoverByYear || opts.Type == CoverNew || opts.Type == CoverAll {
-		switch opts.Genre {
-		case Films:
-			q.Set("genre", "1")
-		case Series:
-			q.Set("genre", "2")
-		case Cartoons:
-			q.Set("genre", "3")
-		case Show:
-			q.Set("genre", "4")
-		case Anime:
-			q.Set("genre", "82")
+		if id, found := genreIDs[opts.Genre]; found {
+			q.Set("genre", id)
 		}
 	}
 	coverURL.RawQuery = q.Encode()
@@ -113,6 +135,19 @@ func (r *HDRezka) GetCovers(opts CoverOption, maxItems int) ([]*CoverItem, error
 	return r.getItems(uri, maxItems)
 }
 
+// Covers lazily iterates video covers with options. Listing pages are
+// fetched one at a time as the loop consumes them, so breaking out early
+// saves the remaining requests. Each item carries its Page and TotalPages.
+func (r *HDRezka) Covers(opts CoverOption) iter.Seq2[*CoverItem, error] {
+	uri, err := r.GetCoversURL(opts)
+	if err != nil {
+		return func(yield func(*CoverItem, error) bool) {
+			yield(nil, err)
+		}
+	}
+	return r.iterItems(uri)
+}
+
 // GetCoversNewest returns newest video covers by genres.
 func (r *HDRezka) GetCoversNewest(genre Genre) ([]*CoverItem, error) {
 	id := "0"
@@ -141,18 +176,73 @@ func (r *HDRezka) GetCoversNewest(genre Genre) ([]*CoverItem, error) {
 
 	items := []*CoverItem{}
 	doc.Find("div.b-content__inline_item").Each(func(i int, s *goquery.Selection) {
-		link := s.Find("div.b-content__inline_item-link > a")
+		item := newCoverItem(s)
 		info, err := s.Find("span.info").Html()
 		if err == nil && info != "" {
-			info = strings.ReplaceAll(info, "<br/>", " ")
+			item.Info = strings.ReplaceAll(info, "<br/>", " ")
 		}
-		items = append(items, &CoverItem{
-			Cover:       s.Find("div.b-content__inline_item-cover > a > img").AttrOr("src", ""),
-			Description: strings.ReplaceAll(s.Find("div.b-content__inline_item-link > div").Text(), " - ...", ""),
-			Info:        info,
-			Title:       link.Text(),
-			URL:         link.AttrOr("href", ""),
-		})
+		items = append(items, item)
 	})
 	return items, nil
 }
+
+// newCoverItem parses a "b-content__inline_item" listing card.
+func newCoverItem(s *goquery.Selection) *CoverItem {
+	link := s.Find("div.b-content__inline_item-link > a")
+	item := &CoverItem{
+		Cover:       s.Find("div.b-content__inline_item-cover > a > img").AttrOr("src", ""),
+		Description: strings.ReplaceAll(s.Find("div.b-content__inline_item-link > div").Text(), " - ...", ""),
+		Info:        s.Find("span.info").Text(),
+		Title:       link.Text(),
+		URL:         link.AttrOr("href", ""),
+		ID:          s.AttrOr("data-id", ""),
+	}
+	if item.ID == "" {
+		item.ID = videoIDFromURL(item.URL)
+	}
+
+	for _, class := range strings.Fields(s.Find("span.cat").AttrOr("class", "")) {
+		if genre := Genre(class); slices.Contains(genres, genre) {
+			item.Type = genre
+		}
+	}
+	if item.Type == All {
+		item.Type = genreFromURL(item.URL)
+	}
+
+	parts := strings.Split(item.Description, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	if year := reYear.FindString(parts[0]); year != "" {
+		item.Year = parseInt(year)
+		parts = parts[1:]
+	}
+	// The last part is the category, any before it are countries
+	if n := len(parts); n > 0 {
+		if parts[n-1] != "" {
+			item.Categories = []string{parts[n-1]}
+		}
+		parts = parts[:n-1]
+	}
+	for _, country := range parts {
+		if country != "" {
+			item.Countries = append(item.Countries, country)
+		}
+	}
+
+	item.Rating.Score = parseFloat(strings.Trim(strings.TrimSpace(s.Find(".b-category-bestrating").Text()), "()"))
+	item.LatestSeason, item.LatestEpisode = parseSeriesStatus(item.Info)
+	return item
+}
+
+// parseSeriesStatus extracts the numbers from a "2 сезон, 8 серия" badge.
+func parseSeriesStatus(status string) (season, episode int) {
+	if m := reStatusSeason.FindStringSubmatch(status); m != nil {
+		season = parseInt(m[1])
+	}
+	if m := reStatusEpisode.FindStringSubmatch(status); m != nil {
+		episode = parseInt(m[1])
+	}
+	return season, episode
+}
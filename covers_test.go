@@ -1,7 +1,15 @@
 package hdrezka
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
 	"testing"
+
+	"github.com/PuerkitoBio/goquery"
 )
 
 func TestHDRezkaGetCoversURL(t *testing.T) {
@@ -82,3 +90,157 @@ func TestHDRezkaGetCoversURL(t *testing.T) {
 		})
 	}
 }
+
+// listingPage renders a minimal cover listing with the site's navigation
+// markup. next is the href of the "next page" arrow, empty on the last page.
+func listingPage(titles []string, next string, pages int) string {
+	var b strings.Builder
+	b.WriteString(`<html><body><div class="b-content__inline_items">`)
+	for _, title := range titles {
+		fmt.Fprintf(&b, `<div class="b-content__inline_item"><div class="b-content__inline_item-link"><a href="/films/drama/%s.html">%s</a><div>2023, США, Драмы</div></div></div>`, title, title)
+	}
+	b.WriteString(`</div><div class="b-navigation">`)
+	for i := 1; i <= pages; i++ {
+		fmt.Fprintf(&b, `<a href="/page/%d/">%d</a> `, i, i)
+	}
+	if next != "" {
+		fmt.Fprintf(&b, `<a href="%s"><span class="b-navigation__next i-sprt">&nbsp;</span></a>`, next)
+	}
+	b.WriteString(`</div></body></html>`)
+	return b.String()
+}
+
+func TestHDRezkaCovers(t *testing.T) {
+	var requests atomic.Int32
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		switch req.URL.Path {
+		case "/":
+			fmt.Fprint(w, listingPage([]string{"a", "b"}, srv.URL+"/page/2/", 2))
+		case "/page/2/":
+			fmt.Fprint(w, listingPage([]string{"c"}, "", 2))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer srv.Close()
+
+	r := New()
+	r.URL, _ = url.Parse(srv.URL)
+
+	var titles []string
+	for item, err := range r.Covers(CoverOption{}) {
+		if err != nil {
+			t.Fatalf("Covers() error = %v", err)
+		}
+		if item.TotalPages != 2 {
+			t.Errorf("item %q TotalPages = %d, want 2", item.Title, item.TotalPages)
+		}
+		titles = append(titles, fmt.Sprintf("%s@%d", item.Title, item.Page))
+	}
+	if got, want := strings.Join(titles, ","), "a@1,b@1,c@2"; got != want {
+		t.Errorf("Covers() items = %s, want %s", got, want)
+	}
+
+	requests.Store(0)
+	for range r.Covers(CoverOption{}) {
+		break
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("early break issued %d requests, want 1", n)
+	}
+
+	items, err := r.GetCovers(CoverOption{}, 2)
+	if err != nil {
+		t.Fatalf("GetCovers() error = %v", err)
+	}
+	if len(items) != 2 {
+		t.Errorf("GetCovers() returned %d items, want 2", len(items))
+	}
+}
+
+func TestNewCoverItem(t *testing.T) {
+	t.Parallel()
+
+	const card = `<div class="b-content__inline_item" data-id="65036">
+	<div class="b-content__inline_item-cover"><a href="https://hdrezka.ag/series/drama/65036-title-2023.html">
+		<img src="https://static.hdrezka.ag/cover.jpg" />
+		<span class="cat series"><i class="entity">Сериал</i><i class="icon"></i></span>
+		<span class="info">2 сезон, 8 серия <i class="voice">(HDrezka Studio)</i></span>
+	</a></div>
+	<div class="b-content__inline_item-link"><a href="https://hdrezka.ag/series/drama/65036-title-2023.html">Title</a><div>2023 - ..., США, Великобритания, Драмы</div></div>
+</div>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(card))
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := newCoverItem(doc.Find("div.b-content__inline_item"))
+
+	if item.ID != "65036" {
+		t.Errorf("ID = %q, want %q", item.ID, "65036")
+	}
+	if item.Type != Series {
+		t.Errorf("Type = %q, want %q", item.Type, Series)
+	}
+	if item.Year != 2023 {
+		t.Errorf("Year = %d, want 2023", item.Year)
+	}
+	if got := strings.Join(item.Countries, "|"); got != "США|Великобритания" {
+		t.Errorf("Countries = %q, want %q", got, "США|Великобритания")
+	}
+	if got := strings.Join(item.Categories, "|"); got != "Драмы" {
+		t.Errorf("Categories = %q, want %q", got, "Драмы")
+	}
+	if item.LatestSeason != 2 || item.LatestEpisode != 8 {
+		t.Errorf("LatestSeason/LatestEpisode = %d/%d, want 2/8", item.LatestSeason, item.LatestEpisode)
+	}
+
+	tests := []struct {
+		line           string
+		wantYear       int
+		wantCountries  string
+		wantCategories string
+	}{
+		{"2023, Драмы", 2023, "", "Драмы"},
+		{"2008 - 2013, США, Триллеры", 2008, "США", "Триллеры"},
+		{"Франция, Комедии", 0, "Франция", "Комедии"},
+		{"2023", 2023, "", ""},
+	}
+	for _, tt := range tests {
+		line := strings.Replace(card, "2023 - ..., США, Великобритания, Драмы", tt.line, 1)
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+		item := newCoverItem(doc.Find("div.b-content__inline_item"))
+		if countries, categories := strings.Join(item.Countries, "|"), strings.Join(item.Categories, "|"); item.Year != tt.wantYear ||
+			countries != tt.wantCountries || categories != tt.wantCategories {
+			t.Errorf("newCoverItem(%q) year, countries, categories = %d, %q, %q, want %d, %q, %q",
+				tt.line, item.Year, countries, categories, tt.wantYear, tt.wantCountries, tt.wantCategories)
+		}
+	}
+}
+
+func TestVideoIDFromURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		url       string
+		wantID    string
+		wantGenre Genre
+	}{
+		{"https://hdrezka.ag/films/drama/12345-title-2023.html", "12345", Films},
+		{"/animation/adventures/777-anime.html", "777", Anime},
+		{"https://hdrezka.ag/page/2/", "", All},
+	}
+	for _, tt := range tests {
+		if got := videoIDFromURL(tt.url); got != tt.wantID {
+			t.Errorf("videoIDFromURL(%q) = %q, want %q", tt.url, got, tt.wantID)
+		}
+		if got := genreFromURL(tt.url); got != tt.wantGenre {
+			t.Errorf("genreFromURL(%q) = %q, want %q", tt.url, got, tt.wantGenre)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package hdrezka
 
 import (
+	"context"
 	"errors"
+	"iter"
 	"net/url"
 	"sort"
 	"strings"
@@ -32,8 +34,93 @@ func (e *Episodes) ListEpisodes(season int) []int {
 	return seasons
 }
 
-// GetEpisodes get episodes for video.
+// StreamFilter selects the episodes Translation.Streams resolves.
+type StreamFilter struct {
+	// Include reports whether an episode is wanted; nil includes all.
+	Include func(season, episode int) bool
+	// Jobs is the number of streams requested ahead of the consumer; at
+	// least one.
+	Jobs int
+}
+
+// EpisodeStream is an episode resolved by Translation.Streams. Stream is nil
+// when resolving it failed.
+type EpisodeStream struct {
+	Season  int
+	Episode int
+	Stream  *Stream
+}
+
+// Streams resolves the stream of every episode that filter includes. Up to
+// filter.Jobs streams are requested concurrently, but they are yielded in
+// season and episode order. A failed episode is yielded with its error and
+// the iteration goes on; a failed episode list or a done ctx ends it.
+func (t *Translation) Streams(ctx context.Context, filter StreamFilter) iter.Seq2[*EpisodeStream, error] {
+	return func(yield func(*EpisodeStream, error) bool) {
+		// Stop the requests still running when the consumer breaks off
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		episodes, err := t.GetEpisodesContext(ctx)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		var wanted []*EpisodeStream
+		for _, season := range episodes.ListSeasons() {
+			for _, episode := range episodes.ListEpisodes(season) {
+				if filter.Include == nil || filter.Include(season, episode) {
+					wanted = append(wanted, &EpisodeStream{Season: season, Episode: episode})
+				}
+			}
+		}
+
+		type pending struct {
+			done chan struct{}
+			err  error
+		}
+		queue := make([]*pending, len(wanted))
+		start := func(i int) {
+			p := &pending{done: make(chan struct{})}
+			queue[i] = p
+			go func() {
+				defer close(p.done)
+				wanted[i].Stream, p.err = t.GetStreamContext(ctx, wanted[i].Season, wanted[i].Episode)
+			}()
+		}
+
+		next := 0
+		for ; next < len(wanted) && next < max(filter.Jobs, 1); next++ {
+			start(next)
+		}
+		for i, item := range wanted {
+			<-queue[i].done
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			if next < len(wanted) {
+				start(next)
+				next++
+			}
+			if !yield(item, queue[i].err) {
+				return
+			}
+		}
+	}
+}
+
+// GetEpisodes get episodes for video. The default translation returns the
+// list rendered into the video page without another request.
 func (t *Translation) GetEpisodes() (Episodes, error) {
+	return t.GetEpisodesContext(context.Background())
+}
+
+// GetEpisodesContext is GetEpisodes with a context that cancels the request.
+func (t *Translation) GetEpisodesContext(ctx context.Context) (Episodes, error) {
+	if t.episodes != nil {
+		return t.episodes, nil
+	}
 	form := url.Values{
 		"id":            {t.videoID},
 		"translator_id": {t.ID},
@@ -44,7 +131,7 @@ func (t *Translation) GetEpisodes() (Episodes, error) {
 		Message  string `json:"message"`
 		Success  bool   `json:"success"`
 	}
-	if err := t.r.getCDN(form, &data); err != nil {
+	if err := t.r.getCDNContext(ctx, form, &data); err != nil {
 		return nil, err
 	}
 	if !data.Success {
@@ -55,9 +142,14 @@ func (t *Translation) GetEpisodes() (Episodes, error) {
 	if err != nil {
 		return nil, err
 	}
+	return parseEpisodes(doc.Selection), nil
+}
 
+// parseEpisodes reads the ".b-simple_episodes__list" episode lists found
+// both in the get_episodes reply and in the video page.
+func parseEpisodes(s *goquery.Selection) Episodes {
 	episodes := make(map[int]map[int]*Stream)
-	doc.Find(".b-simple_episodes__list").Each(func(i int, s *goquery.Selection) {
+	s.Find(".b-simple_episodes__list").Each(func(i int, s *goquery.Selection) {
 		s.Find(".b-simple_episode__item").Each(func(i int, s *goquery.Selection) {
 			season := parseInt(s.AttrOr("data-season_id", ""))
 			episode := parseInt(s.AttrOr("data-episode_id", ""))
@@ -73,5 +165,5 @@ func (t *Translation) GetEpisodes() (Episodes, error) {
 			}
 		})
 	})
-	return episodes, nil
+	return episodes
 }
@@ -0,0 +1,180 @@
+package hdrezka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const seriesPageFixture = `<html><head><title>Series</title></head><body>
+<h1 itemprop="name">Сериал</h1>
+<div class="b-userset__fav_holder" data-post_id="123"></div>
+<ul id="translators-list" class="b-translators__list">
+	<li class="b-translator__item active" data-translator_id="56">Дубляж</li>
+	<li class="b-translator__item" data-translator_id="111">HDrezka Studio</li>
+</ul>
+<div id="simple-episodes-tabs">
+	<ul id="simple-episodes-list-1" class="b-simple_episodes__list">
+		<li class="b-simple_episode__item" data-id="123" data-season_id="1" data-episode_id="1">Серия 1</li>
+		<li class="b-simple_episode__item" data-id="123" data-season_id="1" data-episode_id="2">Серия 2</li>
+	</ul>
+	<ul id="simple-episodes-list-2" class="b-simple_episodes__list">
+		<li class="b-simple_episode__item active" data-id="123" data-season_id="2" data-episode_id="1">Серия 1</li>
+	</ul>
+</div>
+<script>$(function () { sof.tv.initCDNSeriesEvents(123, 56, 2, 1, false, 'hdrezka.ag', false, {"id":"cdnplayer","streams":"[720p]https://cdn.example.com/s2e1.mp4","thumbnails":""}); });</script>
+</body></html>`
+
+func TestGetVideoSeriesState(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/series/drama/123-serial-2020.html" {
+			// get_episodes must not be needed for the default translation
+			t.Errorf("unexpected request %s", req.URL)
+			http.NotFound(w, req)
+			return
+		}
+		io.WriteString(w, seriesPageFixture)
+	}))
+	defer srv.Close()
+
+	r := New()
+	r.URL, _ = url.Parse(srv.URL)
+	video, err := r.GetVideo(srv.URL + "/series/drama/123-serial-2020.html")
+	if err != nil {
+		t.Fatalf("GetVideo() error = %v", err)
+	}
+
+	if video.DefaultSeason != 2 || video.DefaultEpisode != 1 {
+		t.Errorf("GetVideo() default episode = s%de%d, want s2e1", video.DefaultSeason, video.DefaultEpisode)
+	}
+	stream := video.DefaultStream
+	if stream == nil || stream.translation != video.Translation[0] || stream.season != 2 || stream.episode != 1 {
+		t.Errorf("DefaultStream = %+v, want it bound to the default translation and s2e1", stream)
+	}
+
+	episodes, err := video.Translation[0].GetEpisodes()
+	if err != nil {
+		t.Fatalf("GetEpisodes() error = %v", err)
+	}
+	if got := episodes.ListSeasons(); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("ListSeasons() = %v, want [1 2]", got)
+	}
+	if got := episodes.ListEpisodes(1); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("ListEpisodes(1) = %v, want [1 2]", got)
+	}
+	if video.Translation[1].episodes != nil {
+		t.Error("non-default translation got the page episode list")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := video.Translation[1].GetEpisodesContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetEpisodesContext() with a canceled context error = %v", err)
+	}
+}
+
+func TestTranslationStreams(t *testing.T) {
+	t.Parallel()
+
+	srv, peak := newPeakServer(t, func(w http.ResponseWriter, req *http.Request) {
+		season, episode := req.FormValue("season"), req.FormValue("episode")
+		// Later episodes answer first to shake the order
+		time.Sleep(time.Duration(10-parseInt(episode)) * 3 * time.Millisecond)
+		if season == "1" && episode == "2" {
+			io.WriteString(w, "<html>error</html>")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": true,
+			"url":     fmt.Sprintf("[720p]https://cdn.example.com/s%se%s.mp4", season, episode),
+		})
+	})
+
+	r := New()
+	r.URL, _ = url.Parse(srv.URL)
+	episodes := Episodes{}
+	for _, se := range [][2]int{{1, 1}, {1, 2}, {1, 3}, {2, 1}, {2, 2}, {3, 1}} {
+		if episodes[se[0]] == nil {
+			episodes[se[0]] = map[int]*Stream{}
+		}
+		episodes[se[0]][se[1]] = &Stream{}
+	}
+	tr := &Translation{r: r, videoID: "1", ID: "56", episodes: episodes}
+
+	filter := StreamFilter{
+		Include: func(season, episode int) bool { return season < 3 },
+		Jobs:    3,
+	}
+	var got []string
+	for es, err := range tr.Streams(context.Background(), filter) {
+		name := fmt.Sprintf("s%de%d", es.Season, es.Episode)
+		switch {
+		case err != nil:
+			got = append(got, name+" error")
+		case es.Stream.Formats["720p"].MP4 != "https://cdn.example.com/"+name+".mp4":
+			t.Errorf("%s stream = %+v", name, es.Stream.Formats)
+		default:
+			got = append(got, name)
+		}
+	}
+	want := []string{"s1e1", "s1e2 error", "s1e3", "s2e1", "s2e2"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Streams() = %v, want %v", got, want)
+	}
+	if p := peak.Load(); p > 3 {
+		t.Errorf("Streams() ran %d requests at once, want at most 3", p)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for es, err := range tr.Streams(ctx, StreamFilter{}) {
+		if es != nil || err != context.Canceled {
+			t.Errorf("Streams() with cancelled context = %v, %v, want context.Canceled", es, err)
+		}
+	}
+}
+
+func TestTranslationStreamsBreak(t *testing.T) {
+	t.Parallel()
+
+	var canceled atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.FormValue("episode") != "1" {
+			// Hang until the iterator gives up on the request
+			select {
+			case <-req.Context().Done():
+				canceled.Add(1)
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "url": "[720p]https://cdn.example.com/1.mp4"})
+	}))
+	defer srv.Close()
+
+	r := New()
+	r.URL, _ = url.Parse(srv.URL)
+	episodes := Episodes{1: {1: &Stream{}, 2: &Stream{}, 3: &Stream{}, 4: &Stream{}}}
+	tr := &Translation{r: r, videoID: "1", ID: "56", episodes: episodes}
+
+	for range tr.Streams(context.Background(), StreamFilter{Jobs: 4}) {
+		break
+	}
+	for deadline := time.Now().Add(5 * time.Second); canceled.Load() < 3 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := canceled.Load(); n != 3 {
+		t.Errorf("Streams() left %d of 3 requests running after break", 3-n)
+	}
+}
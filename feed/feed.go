@@ -0,0 +1,340 @@
+// Package feed renders HDrezka releases and watcher events as RSS 2.0 and
+// Atom feeds.
+package feed
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/n0madic/go-hdrezka"
+	"github.com/n0madic/go-hdrezka/watcher"
+)
+
+// Feed is a list of entries with the channel metadata of both formats.
+type Feed struct {
+	Title       string
+	Link        string
+	Description string
+	// Author is the feed author Atom requires; "HDrezka" when empty.
+	Author string
+	// Updated is the feed date and the date of entries without their own;
+	// the time of rendering when zero.
+	Updated time.Time
+	Items   []Item
+}
+
+// Item is a feed entry.
+type Item struct {
+	// GUID is stable across runs so feed readers do not repeat entries.
+	GUID        string
+	Title       string
+	Link        string
+	Description string
+	// Image is the cover URL, published as an enclosure.
+	Image string
+	// ImageLength is the cover size RSS enclosures state; see
+	// FetchImageLengths. Zero when unknown.
+	ImageLength int64
+	Published   time.Time
+	Categories  []string
+}
+
+// VideoGUID is the GUID of a release. Releases without an ID are told
+// apart by their URL instead, see FromCovers.
+func VideoGUID(videoID string) string {
+	return "urn:hdrezka:video:" + videoID
+}
+
+// EventGUID is the GUID of a watcher event. It tells apart events of
+// the same video by type, translation, episode and quality.
+func EventGUID(e watcher.Event) string {
+	guid := VideoGUID(e.VideoID) + ":" + string(e.Type)
+	if e.TranslationID != "" {
+		guid += ":t" + e.TranslationID
+	}
+	if e.Season > 0 {
+		guid += fmt.Sprintf(":s%d", e.Season)
+	}
+	if e.Episode > 0 {
+		guid += fmt.Sprintf("e%d", e.Episode)
+	}
+	if e.Quality != "" {
+		guid += ":" + e.Quality
+	}
+	return guid
+}
+
+// FromCovers converts the results of GetCovers, GetCoversNewest, Search or
+// QuickSearch into feed items.
+func FromCovers(covers []*hdrezka.CoverItem) []Item {
+	items := make([]Item, 0, len(covers))
+	for _, c := range covers {
+		var description []string
+		for _, s := range []string{c.Description, c.Info} {
+			if s != "" {
+				description = append(description, s)
+			}
+		}
+		guid := VideoGUID(c.ID)
+		if c.ID == "" {
+			guid = c.URL
+		}
+		items = append(items, Item{
+			GUID:        guid,
+			Title:       c.Title,
+			Link:        c.URL,
+			Description: strings.Join(description, "\n"),
+			Image:       c.Cover,
+			Categories:  c.Categories,
+		})
+	}
+	return items
+}
+
+// FetchImageLengths fills ImageLength of items with a cover by sending a
+// HEAD request for it, at most jobs at once. Covers whose size the server
+// does not report keep a zero length.
+func FetchImageLengths(ctx context.Context, client *http.Client, items []Item, jobs int) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	sem := make(chan struct{}, max(jobs, 1))
+	var wg sync.WaitGroup
+	for i := range items {
+		if items[i].Image == "" || items[i].ImageLength > 0 {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(item *Item) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, item.Image, nil)
+			if err != nil {
+				return
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK && resp.ContentLength > 0 {
+				item.ImageLength = resp.ContentLength
+			}
+		}(&items[i])
+	}
+	wg.Wait()
+}
+
+// FromEvents converts watcher events into feed items.
+func FromEvents(events []watcher.Event) []Item {
+	items := make([]Item, 0, len(events))
+	for _, e := range events {
+		var title, description string
+		switch e.Type {
+		case watcher.NewEpisode:
+			title = fmt.Sprintf("%s: season %d episode %d", e.Title, e.Season, e.Episode)
+			description = "New episode in translation " + e.Translation
+		case watcher.NewSeason:
+			title = fmt.Sprintf("%s: season %d", e.Title, e.Season)
+			description = "New season"
+		case watcher.NewTranslation:
+			title = fmt.Sprintf("%s: %s", e.Title, e.Translation)
+			description = "New translation " + e.Translation
+		case watcher.QualityUpgraded:
+			title = fmt.Sprintf("%s: %s", e.Title, e.Quality)
+			description = fmt.Sprintf("Quality upgraded from %s to %s", e.PreviousQuality, e.Quality)
+		default:
+			title, description = e.Title, string(e.Type)
+		}
+		items = append(items, Item{
+			GUID:        EventGUID(e),
+			Title:       title,
+			Link:        e.URL,
+			Description: description,
+			Image:       e.Cover,
+			Published:   e.Time,
+		})
+	}
+	return items
+}
+
+// imageType guesses the MIME type of a cover from its extension.
+func imageType(url string) string {
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+	if t := mime.TypeByExtension(path.Ext(url)); strings.HasPrefix(t, "image/") {
+		return t
+	}
+	return "image/jpeg"
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link,omitempty"`
+	Description string        `xml:"description,omitempty"`
+	Categories  []string      `xml:"category"`
+	Enclosure   *rssEnclosure `xml:"enclosure"`
+	GUID        rssGUID       `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// WriteRSS writes the feed as RSS 2.0.
+func (f *Feed) WriteRSS(w io.Writer) error {
+	updated := f.updated()
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Description,
+			LastBuildDate: updated.Format(time.RFC1123Z),
+		},
+	}
+	for _, item := range f.Items {
+		ri := rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Description,
+			Categories:  item.Categories,
+			GUID:        rssGUID{Value: item.GUID},
+			PubDate:     published(item, updated).Format(time.RFC1123Z),
+		}
+		if item.Image != "" {
+			ri.Enclosure = &rssEnclosure{URL: item.Image, Length: item.ImageLength, Type: imageType(item.Image)}
+		}
+		feed.Channel.Items = append(feed.Channel.Items, ri)
+	}
+	return writeXML(w, feed)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Link    []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Link       []atomLink     `xml:"link"`
+	Summary    string         `xml:"summary,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// WriteAtom writes the feed as Atom.
+func (f *Feed) WriteAtom(w io.Writer) error {
+	updated := f.updated()
+	feed := atomFeed{
+		ID:      "urn:hdrezka:feed",
+		Title:   f.Title,
+		Updated: updated.Format(time.RFC3339),
+		Author:  atomAuthor{Name: f.Author},
+	}
+	if feed.Author.Name == "" {
+		feed.Author.Name = "HDrezka"
+	}
+	if f.Link != "" {
+		feed.ID = f.Link
+		feed.Link = []atomLink{{Rel: "alternate", Href: f.Link}}
+	}
+	for _, item := range f.Items {
+		entry := atomEntry{
+			ID:      item.GUID,
+			Title:   item.Title,
+			Updated: published(item, updated).Format(time.RFC3339),
+			Summary: item.Description,
+		}
+		if item.Link != "" {
+			entry.Link = append(entry.Link, atomLink{Rel: "alternate", Href: item.Link})
+		}
+		if item.Image != "" {
+			entry.Link = append(entry.Link, atomLink{Rel: "enclosure", Type: imageType(item.Image), Href: item.Image})
+		}
+		for _, c := range item.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: c})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return writeXML(w, feed)
+}
+
+func (f *Feed) updated() time.Time {
+	if f.Updated.IsZero() {
+		return time.Now().UTC()
+	}
+	return f.Updated
+}
+
+func published(item Item, updated time.Time) time.Time {
+	if item.Published.IsZero() {
+		return updated
+	}
+	return item.Published
+}
+
+func writeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
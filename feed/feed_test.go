@@ -0,0 +1,205 @@
+package feed
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/n0madic/go-hdrezka"
+	"github.com/n0madic/go-hdrezka/watcher"
+)
+
+var testFeed = Feed{
+	Title:   "HDrezka",
+	Link:    "https://hdrezka.ag/",
+	Updated: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	Items: append(FromCovers([]*hdrezka.CoverItem{{
+		ID:          "646",
+		Title:       "Во все тяжкие",
+		URL:         "https://hdrezka.ag/series/thriller/646-vo-vse-tyazhkie-2008.html",
+		Cover:       "https://static.hdrezka.ag/i/2021/646.png?v=1",
+		Info:        "2008 - 2013, США, Триллеры",
+		Description: "Учитель химии",
+		Categories:  []string{"Триллеры", "Драмы"},
+	}, {
+		Title: "Без ID",
+		URL:   "https://hdrezka.ag/films/drama/no-id.html",
+		Cover: "https://static.hdrezka.ag/i/2021/no-id.jpg",
+	}}), FromEvents([]watcher.Event{{
+		Type:          watcher.NewEpisode,
+		Time:          time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC),
+		VideoID:       "646",
+		URL:           "https://hdrezka.ag/series/thriller/646-vo-vse-tyazhkie-2008.html",
+		Title:         "Во все тяжкие",
+		Translation:   "Дубляж",
+		TranslationID: "56",
+		Season:        5,
+		Episode:       16,
+	}})...),
+}
+
+func TestGUID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		event watcher.Event
+		want  string
+	}{
+		{
+			name:  "episode",
+			event: watcher.Event{Type: watcher.NewEpisode, VideoID: "1", TranslationID: "56", Season: 2, Episode: 3},
+			want:  "urn:hdrezka:video:1:new_episode:t56:s2e3",
+		},
+		{
+			name:  "season",
+			event: watcher.Event{Type: watcher.NewSeason, VideoID: "1", Season: 2},
+			want:  "urn:hdrezka:video:1:new_season:s2",
+		},
+		{
+			name:  "quality",
+			event: watcher.Event{Type: watcher.QualityUpgraded, VideoID: "1", TranslationID: "56", Quality: "1080p"},
+			want:  "urn:hdrezka:video:1:quality_upgraded:t56:1080p",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := EventGUID(tt.event); got != tt.want {
+				t.Errorf("EventGUID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteRSS(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := testFeed.WriteRSS(&buf); err != nil {
+		t.Fatalf("WriteRSS() error = %v", err)
+	}
+	var got rssFeed
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("WriteRSS() wrote invalid XML: %v\n%s", err, buf.String())
+	}
+	if got.Version != "2.0" || len(got.Channel.Items) != 3 {
+		t.Fatalf("WriteRSS() = %+v, want RSS 2.0 with 3 items", got)
+	}
+	cover := got.Channel.Items[0]
+	if cover.GUID.Value != "urn:hdrezka:video:646" || cover.GUID.IsPermaLink {
+		t.Errorf("cover guid = %+v", cover.GUID)
+	}
+	want := rssEnclosure{URL: "https://static.hdrezka.ag/i/2021/646.png?v=1", Type: "image/png"}
+	if cover.Enclosure == nil || *cover.Enclosure != want {
+		t.Errorf("cover enclosure = %+v, want %+v with an unknown length", cover.Enclosure, want)
+	}
+	if cover.Description != "Учитель химии\n2008 - 2013, США, Триллеры" {
+		t.Errorf("cover description = %q", cover.Description)
+	}
+	if cover.PubDate != "Wed, 01 May 2024 12:00:00 +0000" {
+		t.Errorf("cover pubDate = %q, want the feed date", cover.PubDate)
+	}
+	if noID := got.Channel.Items[1]; noID.GUID.Value != "https://hdrezka.ag/films/drama/no-id.html" {
+		t.Errorf("cover without ID guid = %+v, want its URL", noID.GUID)
+	}
+	if event := got.Channel.Items[2]; event.PubDate != "Thu, 02 May 2024 08:00:00 +0000" || event.Enclosure != nil {
+		t.Errorf("event item = %+v", event)
+	}
+}
+
+func TestWriteAtom(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := testFeed.WriteAtom(&buf); err != nil {
+		t.Fatalf("WriteAtom() error = %v", err)
+	}
+	var got atomFeed
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("WriteAtom() wrote invalid XML: %v\n%s", err, buf.String())
+	}
+	if got.ID != "https://hdrezka.ag/" || got.Updated != "2024-05-01T12:00:00Z" || len(got.Entries) != 3 {
+		t.Fatalf("WriteAtom() = %+v", got)
+	}
+	cover := got.Entries[0]
+	if len(cover.Link) != 2 || cover.Link[1].Rel != "enclosure" || cover.Link[1].Type != "image/png" {
+		t.Errorf("cover links = %+v, want alternate and enclosure", cover.Link)
+	}
+	if len(cover.Categories) != 2 {
+		t.Errorf("cover categories = %+v", cover.Categories)
+	}
+	if got.Author.Name != "HDrezka" {
+		t.Errorf("WriteAtom() author = %+v, want the default", got.Author)
+	}
+	if event := got.Entries[2]; event.ID != "urn:hdrezka:video:646:new_episode:t56:s5e16" ||
+		!strings.Contains(event.Title, "season 5 episode 16") {
+		t.Errorf("event entry = %+v", event)
+	}
+}
+
+func TestFetchImageLengths(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodHead {
+			t.Errorf("request method = %s, want HEAD", req.Method)
+		}
+		switch req.URL.Path {
+		case "/1.jpg":
+			w.Header().Set("Content-Length", "2048")
+		case "/missing.jpg":
+			http.NotFound(w, req)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	f := Feed{Title: "Covers", Items: FromCovers([]*hdrezka.CoverItem{
+		{ID: "1", Title: "Cover", Cover: srv.URL + "/1.jpg"},
+		{ID: "2", Title: "Missing", Cover: srv.URL + "/missing.jpg"},
+		{ID: "3", Title: "No cover"},
+	})}
+	FetchImageLengths(context.Background(), srv.Client(), f.Items, 2)
+	var buf bytes.Buffer
+	if err := f.WriteRSS(&buf); err != nil {
+		t.Fatalf("WriteRSS() error = %v", err)
+	}
+	var got rssFeed
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("WriteRSS() wrote invalid XML: %v", err)
+	}
+	want := []*rssEnclosure{
+		{URL: srv.URL + "/1.jpg", Length: 2048, Type: "image/jpeg"},
+		{URL: srv.URL + "/missing.jpg", Type: "image/jpeg"},
+		nil,
+	}
+	for i, w := range want {
+		e := got.Channel.Items[i].Enclosure
+		if (e == nil) != (w == nil) || e != nil && *e != *w {
+			t.Errorf("item %d enclosure = %+v, want %+v", i, e, w)
+		}
+	}
+}
+
+func TestRSSEnclosure(t *testing.T) {
+	t.Parallel()
+
+	f := Feed{Title: "Covers", Items: []Item{{GUID: "1", Title: "Cover", Image: "https://example.com/1.jpg", ImageLength: 2048}}}
+	var buf bytes.Buffer
+	if err := f.WriteRSS(&buf); err != nil {
+		t.Fatalf("WriteRSS() error = %v", err)
+	}
+	var got rssFeed
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("WriteRSS() wrote invalid XML: %v", err)
+	}
+	want := &rssEnclosure{URL: "https://example.com/1.jpg", Length: 2048, Type: "image/jpeg"}
+	if e := got.Channel.Items[0].Enclosure; e == nil || *e != *want {
+		t.Errorf("enclosure = %+v, want %+v", e, want)
+	}
+}
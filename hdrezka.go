@@ -2,6 +2,7 @@
 package hdrezka
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -38,6 +39,12 @@ type HDRezka struct {
 	URL *url.URL
 	// Categories is a map of categories by genre and their urls
 	Categories map[Genre]map[string]string
+	// Countries maps country names to the slug used in "/country/<slug>/"
+	// listings
+	Countries map[string]string
+	// Filters maps the listing filters (sort orders) offered by the site to
+	// their display names
+	Filters map[Filter]string
 	// Years is list of years for filtering
 	Years []string
 	// Client is the HTTP client used for all site requests. Its cookie jar
@@ -48,12 +55,23 @@ type HDRezka struct {
 	proxyAddr      string
 	resolverAddr   string
 	persistSession bool
+	rateInterval   time.Duration
 	initialized    bool
 }
 
-func (r *HDRezka) getCDN(form url.Values, data interface{}) error {
-	cdnURL := r.URL.JoinPath("/ajax/get_cdn_series/").String() + "?t=" + strconv.FormatInt(time.Now().UnixNano(), 10)
-	req, err := http.NewRequest(http.MethodPost, cdnURL, strings.NewReader(form.Encode()))
+func (r *HDRezka) getCDNContext(ctx context.Context, form url.Values, data interface{}) error {
+	return r.postAJAXContext(ctx, "/ajax/get_cdn_series/", form, data)
+}
+
+// postAJAX posts form to an AJAX endpoint the way the site player does and
+// decodes the JSON reply into data.
+func (r *HDRezka) postAJAX(endpoint string, form url.Values, data interface{}) error {
+	return r.postAJAXContext(context.Background(), endpoint, form, data)
+}
+
+func (r *HDRezka) postAJAXContext(ctx context.Context, endpoint string, form url.Values, data interface{}) error {
+	ajaxURL := r.URL.JoinPath(endpoint).String() + "?t=" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ajaxURL, strings.NewReader(form.Encode()))
 	if err != nil {
 		return err
 	}
@@ -72,7 +90,8 @@ func (r *HDRezka) getCDN(form url.Values, data interface{}) error {
 
 // New creates a new HDRezka. It performs no network I/O — configure the
 // instance with WithMirrors / WithProxy / WithResolver and then call Init
-// to probe mirrors and populate URL / Categories / Years before using
+// to probe mirrors and populate URL / Categories / Countries / Filters /
+// Years before using
 // any other method.
 func New() *HDRezka {
 	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
@@ -115,6 +134,9 @@ func (r *HDRezka) Init() error {
 	if err != nil {
 		return err
 	}
+	if r.rateInterval > 0 {
+		transport = newRateLimitTransport(transport, r.rateInterval, r.isSiteRequest)
+	}
 	r.Client.Transport = transport
 
 	mirrors := r.mirrors
@@ -147,19 +169,7 @@ func (r *HDRezka) Init() error {
 	// hosts differ, so the jar never leaks these to them.
 	r.Client.Jar.SetCookies(r.URL, browserCookies)
 
-	r.Categories[Films] = getCategory("li.b-topnav__item.i1 > div > div > ul.left > li", doc)
-	r.Categories[Series] = getCategory("li.b-topnav__item.i2 > div > div > ul.left > li", doc)
-	r.Categories[Cartoons] = getCategory("li.b-topnav__item.i3 > div > div > ul.left > li", doc)
-	r.Categories[Anime] = getCategory("li.b-topnav__item.i5 > div > div > ul.left > li", doc)
-	r.Categories[Show] = categoriesShow
-
-	r.Years = r.Years[:0]
-	doc.Find("#find-best-block-1 > div > select.select-year > option").Each(func(i int, s *goquery.Selection) {
-		if s.Text() == "за все время" {
-			return
-		}
-		r.Years = append(r.Years, s.Text())
-	})
+	r.discoverCatalog(doc)
 
 	r.initialized = true
 	return nil
@@ -0,0 +1,37 @@
+// Package vtt holds the WebVTT parsing shared by the thumbnail tracks of
+// the hdrezka package and the subtitles package.
+package vtt
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseTimestamp parses "hh:mm:ss.ttt" or "mm:ss.ttt"; the SRT comma is
+// accepted too. Cue settings after the timestamp are ignored.
+func ParseTimestamp(str string) (time.Duration, error) {
+	fields := strings.Fields(str)
+	if len(fields) == 0 {
+		return 0, errors.New("empty timestamp")
+	}
+	parts := strings.Split(strings.Replace(fields[0], ",", ".", 1), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", str)
+	}
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q", str)
+	}
+	d := time.Duration(seconds * float64(time.Second))
+	for i, unit := range []time.Duration{time.Minute, time.Hour}[:len(parts)-1] {
+		n, err := strconv.Atoi(parts[len(parts)-2-i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp %q", str)
+		}
+		d += time.Duration(n) * unit
+	}
+	return d.Round(time.Millisecond), nil
+}
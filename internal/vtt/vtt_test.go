@@ -0,0 +1,32 @@
+package vtt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"00:01:02.345", time.Minute + 2345*time.Millisecond, false},
+		{"01:02.5", time.Minute + 2500*time.Millisecond, false},
+		{"1:00:00.000", time.Hour, false},
+		{"00:00:01,250", 1250 * time.Millisecond, false},
+		{"00:00:05.000 line:90% align:center", 5 * time.Second, false},
+		{"", 0, true},
+		{"12.5", 0, true},
+		{"00:xx:01.000", 0, true},
+		{"1:2:3:4", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTimestamp(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseTimestamp(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package hdrezka
+
+import (
+	"strings"
+)
+
+// Language is an ISO 639-1 language code such as "ru" or "uk". An empty
+// Language means the language is unknown (for example original audio).
+type Language string
+
+// SubtitleTrack is a subtitle language offered by a stream
+type SubtitleTrack struct {
+	// Label is the name shown in the player, e.g. "Українська".
+	Label    string   `json:"label"`
+	Language Language `json:"language,omitempty"`
+	URL      string   `json:"url"`
+	// URLs lists every CDN alternative, URL first.
+	URLs []string `json:"urls,omitempty"`
+	// Default marks the track the player enables on start (subtitle_def).
+	Default bool `json:"default,omitempty"`
+}
+
+// iso6392 maps ISO 639-1 codes to ISO 639-2/B codes.
+var iso6392 = map[Language]string{
+	"ar": "ara", "be": "bel", "cs": "cze", "de": "ger", "en": "eng",
+	"es": "spa", "fr": "fre", "he": "heb", "hy": "arm", "it": "ita",
+	"ja": "jpn", "ka": "geo", "kk": "kaz", "ko": "kor", "lt": "lit",
+	"lv": "lav", "nl": "dut", "pl": "pol", "pt": "por", "ro": "rum",
+	"ru": "rus", "sv": "swe", "tr": "tur", "uk": "ukr", "uz": "uzb",
+	"zh": "chi",
+}
+
+// languageNames maps lower-cased labels used by the site for subtitles and
+// audio flags to languages.
+var languageNames = map[string]Language{
+	"русский": "ru", "russian": "ru",
+	"українська": "uk", "украинский": "uk", "ukrainian": "uk",
+	"english": "en", "английский": "en",
+	"қазақша": "kk", "казахский": "kk",
+	"беларуская": "be", "белорусский": "be",
+	"deutsch": "de", "немецкий": "de",
+	"français": "fr", "французский": "fr",
+	"español": "es", "испанский": "es",
+	"italiano": "it", "итальянский": "it",
+	"português": "pt", "португальский": "pt",
+	"polski": "pl", "польский": "pl",
+	"türkçe": "tr", "турецкий": "tr",
+	"日本語": "ja", "японский": "ja",
+	"한국어": "ko", "корейский": "ko",
+	"中文": "zh", "китайский": "zh",
+	"עברית": "he", "иврит": "he",
+	"العربية": "ar", "арабский": "ar",
+}
+
+// translationHints are substrings of translation names that reveal the
+// audio language. An empty Language marks original-audio tracks.
+var translationHints = []struct {
+	hint     string
+	language Language
+}{
+	{"оригинал", ""},
+	{"original", ""},
+	{"субтитры", ""},
+	{"украин", "uk"},
+	{"(укр", "uk"},
+	{"английск", "en"},
+	{"казах", "kk"},
+	{"белорус", "be"},
+}
+
+// ISO6392 returns the three-letter ISO 639-2/B code many muxers expect, or
+// "und" when the language is unknown.
+func (l Language) ISO6392() string {
+	if code, found := iso6392[l]; found {
+		return code
+	}
+	return "und"
+}
+
+// normalizeLanguageCode maps the site's own codes to ISO 639-1. The site
+// uses "ua" for Ukrainian.
+func normalizeLanguageCode(code string) Language {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "ua" {
+		return "uk"
+	}
+	return Language(code)
+}
+
+// detectLanguage guesses the language of a subtitle label or flag title.
+func detectLanguage(label string) Language {
+	return languageNames[strings.ToLower(strings.TrimSpace(label))]
+}
+
+// translationLanguage detects the audio language of a translation from the
+// title of its flag icon and its name. Voice-overs without any hint are
+// Russian, which is the site default.
+func translationLanguage(name, flag string) Language {
+	if lang := detectLanguage(flag); lang != "" {
+		return lang
+	}
+	lower := strings.ToLower(name)
+	for _, h := range translationHints {
+		if strings.Contains(lower, h.hint) {
+			return h.language
+		}
+	}
+	return "ru"
+}
+
+// parseSubtitleTracks builds the typed subtitle list from the raw
+// "subtitle", "subtitle_lns" and "subtitle_def" player values. The latter two
+// are false rather than objects or strings when the stream has no subtitles.
+func parseSubtitleTracks(subs string, lns, def any) []SubtitleTrack {
+	codes, _ := lns.(map[string]any)
+	defCode, _ := def.(string)
+
+	var tracks []SubtitleTrack
+	for _, entry := range strings.Split(subs, ",") {
+		endBracket := strings.Index(entry, "]")
+		if endBracket == -1 {
+			continue
+		}
+		label := strings.TrimSpace(entry[1:endBracket])
+		// A subtitle entry may carry alternatives separated by " or "; the
+		// site/app uses the last one, the others are backup nodes.
+		parts := strings.Split(strings.TrimSpace(entry[endBracket+1:]), " or ")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		urls := append([]string{parts[len(parts)-1]}, parts[:len(parts)-1]...)
+
+		track := SubtitleTrack{Label: label, URL: urls[0], URLs: urls}
+		code, _ := codes[label].(string)
+		if code != "" {
+			track.Language = normalizeLanguageCode(code)
+			track.Default = code == defCode
+		} else {
+			track.Language = detectLanguage(label)
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks
+}
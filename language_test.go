@@ -0,0 +1,61 @@
+package hdrezka
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSubtitleTracks(t *testing.T) {
+	t.Parallel()
+
+	lns := map[string]any{"off": "", "Русский": "ru", "Українська": "ua", "English": "en"}
+	tracks := parseSubtitleTracks("[Русский]http://a.vtt,[Українська]http://b.vtt,[English]http://c.vtt or http://d.vtt,[Srpski]http://e.vtt", lns, "ua")
+
+	want := []SubtitleTrack{
+		{Label: "Русский", Language: "ru", URL: "http://a.vtt", URLs: []string{"http://a.vtt"}},
+		{Label: "Українська", Language: "uk", URL: "http://b.vtt", URLs: []string{"http://b.vtt"}, Default: true},
+		{Label: "English", Language: "en", URL: "http://d.vtt", URLs: []string{"http://d.vtt", "http://c.vtt"}},
+		{Label: "Srpski", URL: "http://e.vtt", URLs: []string{"http://e.vtt"}},
+	}
+	if len(tracks) != len(want) {
+		t.Fatalf("parseSubtitleTracks() = %+v, want %+v", tracks, want)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(tracks[i], want[i]) {
+			t.Errorf("track[%d] = %+v, want %+v", i, tracks[i], want[i])
+		}
+	}
+
+	// Without subtitle_lns (false in the player JSON) labels are matched by name.
+	tracks = parseSubtitleTracks("[Українська]http://b.vtt", false, false)
+	if len(tracks) != 1 || tracks[0].Language != "uk" || tracks[0].Default {
+		t.Errorf("parseSubtitleTracks() without codes = %+v, want uk, not default", tracks)
+	}
+}
+
+func TestTranslationLanguage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name, flag string
+		want       Language
+	}{
+		{"HDrezka Studio", "", "ru"},
+		{"Дубляж", "Украинский", "uk"},
+		{"Сварог (Украинский)", "", "uk"},
+		{"Оригинал (+субтитры)", "", ""},
+		{"Английский", "", "en"},
+	}
+	for _, tt := range tests {
+		if got := translationLanguage(tt.name, tt.flag); got != tt.want {
+			t.Errorf("translationLanguage(%q, %q) = %q, want %q", tt.name, tt.flag, got, tt.want)
+		}
+	}
+
+	if got := Language("uk").ISO6392(); got != "ukr" {
+		t.Errorf("Language(uk).ISO6392() = %q, want ukr", got)
+	}
+	if got := Language("").ISO6392(); got != "und" {
+		t.Errorf("Language().ISO6392() = %q, want und", got)
+	}
+}
@@ -0,0 +1,251 @@
+// Package nfo renders HDrezka videos as the NFO metadata files read by
+// Kodi, Jellyfin and Emby.
+package nfo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/n0madic/go-hdrezka"
+)
+
+// Rating is a score of one rating source.
+type Rating struct {
+	Name    string  `xml:"name,attr"`
+	Max     int     `xml:"max,attr"`
+	Default bool    `xml:"default,attr,omitempty"`
+	Value   float64 `xml:"value"`
+	Votes   int     `xml:"votes,omitempty"`
+}
+
+// UniqueID is an ID of the video in an external database.
+type UniqueID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr,omitempty"`
+	Value   string `xml:",chardata"`
+}
+
+// Actor is a cast member.
+type Actor struct {
+	Name  string `xml:"name"`
+	Order int    `xml:"order"`
+}
+
+// Thumb is an artwork URL.
+type Thumb struct {
+	Aspect string `xml:"aspect,attr,omitempty"`
+	URL    string `xml:",chardata"`
+}
+
+// Movie is the content of movie.nfo.
+type Movie struct {
+	XMLName       xml.Name   `xml:"movie"`
+	Title         string     `xml:"title"`
+	OriginalTitle string     `xml:"originaltitle,omitempty"`
+	Year          int        `xml:"year,omitempty"`
+	Premiered     string     `xml:"premiered,omitempty"`
+	Plot          string     `xml:"plot,omitempty"`
+	Tagline       string     `xml:"tagline,omitempty"`
+	Runtime       int        `xml:"runtime,omitempty"`
+	MPAA          string     `xml:"mpaa,omitempty"`
+	Ratings       []Rating   `xml:"ratings>rating"`
+	UniqueIDs     []UniqueID `xml:"uniqueid"`
+	Genres        []string   `xml:"genre"`
+	Countries     []string   `xml:"country"`
+	Directors     []string   `xml:"director"`
+	Actors        []Actor    `xml:"actor"`
+	Thumbs        []Thumb    `xml:"thumb"`
+}
+
+// TVShow is the content of tvshow.nfo.
+type TVShow struct {
+	XMLName       xml.Name   `xml:"tvshow"`
+	Title         string     `xml:"title"`
+	OriginalTitle string     `xml:"originaltitle,omitempty"`
+	Year          int        `xml:"year,omitempty"`
+	Premiered     string     `xml:"premiered,omitempty"`
+	Plot          string     `xml:"plot,omitempty"`
+	Tagline       string     `xml:"tagline,omitempty"`
+	MPAA          string     `xml:"mpaa,omitempty"`
+	Ratings       []Rating   `xml:"ratings>rating"`
+	UniqueIDs     []UniqueID `xml:"uniqueid"`
+	Genres        []string   `xml:"genre"`
+	Countries     []string   `xml:"country"`
+	Directors     []string   `xml:"director"`
+	Actors        []Actor    `xml:"actor"`
+	Thumbs        []Thumb    `xml:"thumb"`
+}
+
+// EpisodeDetails is the content of the NFO file next to an episode.
+type EpisodeDetails struct {
+	XMLName       xml.Name `xml:"episodedetails"`
+	Title         string   `xml:"title"`
+	OriginalTitle string   `xml:"originaltitle,omitempty"`
+	ShowTitle     string   `xml:"showtitle"`
+	Season        int      `xml:"season"`
+	Episode       int      `xml:"episode"`
+	Aired         string   `xml:"aired,omitempty"`
+	Directors     []string `xml:"director"`
+	Actors        []Actor  `xml:"actor"`
+}
+
+// NewMovie returns the movie.nfo of video.
+func NewMovie(video *hdrezka.Video) *Movie {
+	return &Movie{
+		Title:         video.Title,
+		OriginalTitle: video.TitleOriginal,
+		Year:          video.ReleaseYear,
+		Premiered:     premiered(video),
+		Plot:          video.Description,
+		Tagline:       video.Tagline,
+		Runtime:       int(video.Runtime.Minutes()),
+		MPAA:          mpaa(video),
+		Ratings:       ratings(video),
+		UniqueIDs:     uniqueIDs(video),
+		Genres:        video.Categories,
+		Countries:     video.Country,
+		Directors:     names(video.Director),
+		Actors:        actors(video.Cast),
+		Thumbs:        thumbs(video),
+	}
+}
+
+// NewTVShow returns the tvshow.nfo of video.
+func NewTVShow(video *hdrezka.Video) *TVShow {
+	return &TVShow{
+		Title:         video.Title,
+		OriginalTitle: video.TitleOriginal,
+		Year:          video.ReleaseYear,
+		Premiered:     premiered(video),
+		Plot:          video.Description,
+		Tagline:       video.Tagline,
+		MPAA:          mpaa(video),
+		Ratings:       ratings(video),
+		UniqueIDs:     uniqueIDs(video),
+		Genres:        video.Categories,
+		Countries:     video.Country,
+		Directors:     names(video.Director),
+		Actors:        actors(video.Cast),
+		Thumbs:        thumbs(video),
+	}
+}
+
+// NewEpisode returns the NFO of an episode of video. Titles and air dates
+// come from the release schedule; episodes missing from it are titled
+// "Episode N".
+func NewEpisode(video *hdrezka.Video, season, episode int) *EpisodeDetails {
+	details := &EpisodeDetails{
+		Title:     "Episode " + strconv.Itoa(episode),
+		ShowTitle: video.Title,
+		Season:    season,
+		Episode:   episode,
+		Directors: names(video.Director),
+		Actors:    actors(video.Cast),
+	}
+	if item, found := video.ScheduledEpisode(season, episode); found {
+		if item.Title != "" {
+			details.Title = item.Title
+		}
+		details.OriginalTitle = item.TitleOriginal
+		if !item.Released.IsZero() {
+			details.Aired = item.Released.Format("2006-01-02")
+		}
+	}
+	return details
+}
+
+// Write encodes an NFO returned by NewMovie, NewTVShow or NewEpisode to w
+// as a standalone XML document.
+func Write(w io.Writer, nfo any) error {
+	if _, err := io.WriteString(w, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+"\n"); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(nfo); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func premiered(video *hdrezka.Video) string {
+	if video.Released.IsZero() {
+		return ""
+	}
+	return video.Released.Format("2006-01-02")
+}
+
+func mpaa(video *hdrezka.Video) string {
+	if video.MinAge == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d+", video.MinAge)
+}
+
+// ratings lists the IMDb, Kinopoisk and site ratings, with the first one
+// present as the default.
+func ratings(video *hdrezka.Video) []Rating {
+	var list []Rating
+	for _, r := range []struct {
+		name   string
+		rating hdrezka.Rating
+	}{
+		{"imdb", video.RatingIMDB},
+		{"kinopoisk", video.RatingKinopoisk},
+		{"hdrezka", video.Rating},
+	} {
+		if r.rating.Score > 0 {
+			list = append(list, Rating{
+				Name:    r.name,
+				Max:     10,
+				Default: len(list) == 0,
+				Value:   r.rating.Score,
+				Votes:   r.rating.Votes,
+			})
+		}
+	}
+	return list
+}
+
+// uniqueIDs lists the IMDb and Kinopoisk IDs, with the first one present as
+// the default, followed by the site ID.
+func uniqueIDs(video *hdrezka.Video) []UniqueID {
+	var ids []UniqueID
+	for _, id := range []UniqueID{
+		{Type: "imdb", Value: video.IMDbID},
+		{Type: "kinopoisk", Value: video.KinopoiskID},
+		{Type: "hdrezka", Value: video.ID},
+	} {
+		if id.Value != "" {
+			id.Default = len(ids) == 0
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func names(people []hdrezka.Person) []string {
+	var list []string
+	for _, p := range people {
+		list = append(list, p.Name)
+	}
+	return list
+}
+
+func actors(cast []hdrezka.Person) []Actor {
+	var list []Actor
+	for i, p := range cast {
+		list = append(list, Actor{Name: p.Name, Order: i})
+	}
+	return list
+}
+
+func thumbs(video *hdrezka.Video) []Thumb {
+	if video.Cover == "" {
+		return nil
+	}
+	return []Thumb{{Aspect: "poster", URL: video.Cover}}
+}
@@ -0,0 +1,118 @@
+package nfo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/n0madic/go-hdrezka"
+)
+
+var testVideo = &hdrezka.Video{
+	ID:            "646",
+	Title:         "Во все тяжкие",
+	TitleOriginal: "Breaking Bad",
+	ReleaseYear:   2008,
+	Released:      time.Date(2008, time.January, 20, 0, 0, 0, 0, time.UTC),
+	Description:   "Учитель химии & рак",
+	Tagline:       "Remember my name",
+	Runtime:       47 * time.Minute,
+	MinAge:        18,
+	RatingIMDB:    hdrezka.Rating{Score: 9.5, Votes: 2000000},
+	Rating:        hdrezka.Rating{Score: 9.1, Votes: 1000},
+	IMDbID:        "tt0903747",
+	KinopoiskID:   "404900",
+	Categories:    []string{"Триллеры", "Драмы"},
+	Country:       []string{"США"},
+	Director:      []hdrezka.Person{{Name: "Винс Гиллиган"}},
+	Cast:          []hdrezka.Person{{Name: "Брайан Крэнстон"}, {Name: "Аарон Пол"}},
+	Cover:         "https://static.hdrezka.ag/i/646.jpg",
+	Schedule:      []hdrezka.ScheduleItem{{Season: 1, Episode: 2, Title: "Кот в мешке", TitleOriginal: "Cat's in the Bag...", Released: time.Date(2008, time.January, 27, 0, 0, 0, 0, time.UTC)}},
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		nfo     any
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "movie",
+			nfo:  NewMovie(testVideo),
+			want: []string{
+				"<movie>",
+				"<title>Во все тяжкие</title>",
+				"<originaltitle>Breaking Bad</originaltitle>",
+				"<year>2008</year>",
+				"<premiered>2008-01-20</premiered>",
+				"<plot>Учитель химии &amp; рак</plot>",
+				"<runtime>47</runtime>",
+				"<mpaa>18+</mpaa>",
+				`<rating name="imdb" max="10" default="true">`,
+				"<value>9.5</value>",
+				`<rating name="hdrezka" max="10">`,
+				`<uniqueid type="imdb" default="true">tt0903747</uniqueid>`,
+				`<uniqueid type="kinopoisk">404900</uniqueid>`,
+				`<uniqueid type="hdrezka">646</uniqueid>`,
+				"<genre>Драмы</genre>",
+				"<country>США</country>",
+				"<director>Винс Гиллиган</director>",
+				"<name>Аарон Пол</name>",
+				"<order>1</order>",
+				`<thumb aspect="poster">https://static.hdrezka.ag/i/646.jpg</thumb>`,
+			},
+			notWant: []string{`name="kinopoisk"`},
+		},
+		{
+			name: "tvshow",
+			nfo:  NewTVShow(testVideo),
+			want: []string{"<tvshow>", "<title>Во все тяжкие</title>", `<uniqueid type="imdb" default="true">`},
+		},
+		{
+			name: "scheduled episode",
+			nfo:  NewEpisode(testVideo, 1, 2),
+			want: []string{
+				"<episodedetails>",
+				"<title>Кот в мешке</title>",
+				"<originaltitle>Cat&#39;s in the Bag...</originaltitle>",
+				"<showtitle>Во все тяжкие</showtitle>",
+				"<season>1</season>",
+				"<episode>2</episode>",
+				"<aired>2008-01-27</aired>",
+			},
+		},
+		{
+			name:    "unscheduled episode",
+			nfo:     NewEpisode(testVideo, 2, 5),
+			want:    []string{"<title>Episode 5</title>", "<season>2</season>"},
+			notWant: []string{"<aired>", "<originaltitle>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			if err := Write(&buf, tt.nfo); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			got := buf.String()
+			if !strings.HasPrefix(got, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`) {
+				t.Errorf("Write() has no XML declaration:\n%s", got)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Write() misses %s:\n%s", want, got)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("Write() has %s:\n%s", notWant, got)
+				}
+			}
+		})
+	}
+}
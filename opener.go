@@ -0,0 +1,100 @@
+package hdrezka
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrShortBody is reported for a CDN node that answers 200 with a body
+// shorter than the caller's minimum, typically an error page or an empty file.
+var ErrShortBody = errors.New("response body too short")
+
+// StatusError is returned when a server answers with an unexpected HTTP status.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: unexpected status code: %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// OpenAlternatives GETs urls in order and returns the first usable response,
+// so a dead CDN node falls through to its backups. header is added to every
+// request. A 200 reply must carry at least minSize bytes; a 206 reply to a
+// ranged request is accepted as is, and so is a 416, which tells the caller
+// it already has the whole file. The URL that answered is resp.Request.URL.
+//
+// When every alternative fails the errors of all attempts are joined, so
+// errors.As can find a *StatusError.
+func OpenAlternatives(client *http.Client, urls []string, header http.Header, minSize int64) (*http.Response, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no URLs to open")
+	}
+	var errs []error
+	for _, u := range urls {
+		resp, err := openAlternative(client, u, header, minSize)
+		if err == nil {
+			return resp, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+func openAlternative(client *http.Client, u string, header http.Header, minSize int64) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		return resp, nil
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && req.Header.Get("Range") != "":
+		return resp, nil
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return nil, &StatusError{URL: u, StatusCode: resp.StatusCode}
+	}
+
+	if minSize <= 0 {
+		return resp, nil
+	}
+	if resp.ContentLength >= 0 {
+		if resp.ContentLength < minSize {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s: %w (%d bytes)", u, ErrShortBody, resp.ContentLength)
+		}
+		return resp, nil
+	}
+
+	// Unknown length: read the minimum up front and replay it to the caller.
+	head := make([]byte, minSize)
+	n, err := io.ReadFull(resp.Body, head)
+	if err != nil {
+		resp.Body.Close()
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %w (%d bytes)", u, ErrShortBody, n)
+		}
+		return nil, err
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+	return resp, nil
+}
@@ -0,0 +1,88 @@
+package hdrezka
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAlternatives(t *testing.T) {
+	t.Parallel()
+
+	body := strings.Repeat("x", 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/dead":
+			http.Error(w, "gone", http.StatusInternalServerError)
+		case "/short":
+			io.WriteString(w, "oops")
+		case "/chunked":
+			// Flushing before writing hides the length from the client
+			w.(http.Flusher).Flush()
+			io.WriteString(w, body)
+		case "/ranged":
+			if req.Header.Get("Range") == "bytes=100-" {
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			w.Header().Set("Content-Range", "bytes 50-99/100")
+			w.WriteHeader(http.StatusPartialContent)
+			io.WriteString(w, body[50:])
+		default:
+			io.WriteString(w, body)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name     string
+		paths    []string
+		rng      string
+		wantPath string
+		wantCode int
+		wantBody string
+	}{
+		{"first", []string{"/ok", "/dead"}, "", "/ok", http.StatusOK, body},
+		{"fallback", []string{"/dead", "/short", "/ok"}, "", "/ok", http.StatusOK, body},
+		{"unknown length", []string{"/chunked"}, "", "/chunked", http.StatusOK, body},
+		{"partial", []string{"/ranged"}, "bytes=50-", "/ranged", http.StatusPartialContent, body[50:]},
+		{"complete", []string{"/ranged"}, "bytes=100-", "/ranged", http.StatusRequestedRangeNotSatisfiable, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			urls := make([]string, len(tt.paths))
+			for i, p := range tt.paths {
+				urls[i] = srv.URL + p
+			}
+			header := http.Header{}
+			if tt.rng != "" {
+				header.Set("Range", tt.rng)
+			}
+			resp, err := OpenAlternatives(srv.Client(), urls, header, 10)
+			if err != nil {
+				t.Fatalf("OpenAlternatives() error = %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.Request.URL.Path != tt.wantPath || resp.StatusCode != tt.wantCode {
+				t.Errorf("OpenAlternatives() = %s %d, want %s %d", resp.Request.URL.Path, resp.StatusCode, tt.wantPath, tt.wantCode)
+			}
+			got, _ := io.ReadAll(resp.Body)
+			if string(got) != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+
+	_, err := OpenAlternatives(srv.Client(), []string{srv.URL + "/dead", srv.URL + "/short"}, nil, 10)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("OpenAlternatives() error = %v, want a *StatusError with 500", err)
+	}
+	if !errors.Is(err, ErrShortBody) {
+		t.Errorf("OpenAlternatives() error = %v, want ErrShortBody", err)
+	}
+}
@@ -0,0 +1,145 @@
+package hdrezka
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafov/m3u8"
+)
+
+// FormatProbe is what Stream.Probe learned about one quality.
+type FormatProbe struct {
+	Quality Quality `json:"quality"`
+	// Size is the MP4 file size in bytes, or -1 when unknown.
+	Size int64 `json:"size"`
+	// Bitrate is the average bitrate in bits per second: Size over Duration
+	// when both are known, otherwise the HLS variant bandwidth.
+	Bitrate  int64         `json:"bitrate,omitempty"`
+	Width    int           `json:"width,omitempty"`
+	Height   int           `json:"height,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	// Err is set when neither the MP4 nor the HLS playlist could be read.
+	Err error `json:"-"`
+}
+
+// Probe measures every available quality concurrently. It asks for the
+// first byte of each MP4 to learn its size and reads the HLS master and
+// media playlists for resolution, bandwidth and duration. The result is
+// ordered like Qualities.
+func (s *Stream) Probe() []FormatProbe {
+	client := http.DefaultClient
+	if s.r != nil {
+		client = s.r.Client
+	}
+
+	qualities := s.Qualities()
+	probes := make([]FormatProbe, len(qualities))
+	var wg sync.WaitGroup
+	for i, q := range qualities {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			probes[i] = probeFormat(client, q, s.Formats[q.Label])
+		}()
+	}
+	wg.Wait()
+	return probes
+}
+
+func probeFormat(client *http.Client, q Quality, format VideoFormat) FormatProbe {
+	probe := FormatProbe{Quality: q, Size: -1, Height: q.Height}
+
+	var mp4Err, hlsErr error
+	if len(format.MP4URLs) > 0 {
+		probe.Size, mp4Err = probeSize(client, format.MP4URLs)
+	} else {
+		mp4Err = errors.New("no MP4 links")
+	}
+
+	var bandwidth int64
+	if len(format.HLSURLs) > 0 {
+		bandwidth, hlsErr = probePlaylist(client, format.HLSURLs, &probe)
+	} else {
+		hlsErr = errors.New("no HLS links")
+	}
+
+	switch {
+	case probe.Size > 0 && probe.Duration > 0:
+		probe.Bitrate = probe.Size * 8 * int64(time.Second) / int64(probe.Duration)
+	case bandwidth > 0:
+		probe.Bitrate = bandwidth
+	}
+	if mp4Err != nil && hlsErr != nil {
+		probe.Err = errors.Join(mp4Err, hlsErr)
+	}
+	return probe
+}
+
+// probeSize requests the first byte of the file and reads the total size
+// from Content-Range, or from Content-Length when ranges are ignored.
+func probeSize(client *http.Client, urls []string) (int64, error) {
+	resp, err := OpenAlternatives(client, urls, http.Header{"Range": {"bytes=0-0"}}, 0)
+	if err != nil {
+		return -1, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusPartialContent {
+		_, total, found := strings.Cut(resp.Header.Get("Content-Range"), "/")
+		if size, err := strconv.ParseInt(total, 10, 64); found && err == nil {
+			return size, nil
+		}
+		return -1, fmt.Errorf("%s: no total size in Content-Range", resp.Request.URL)
+	}
+	return resp.ContentLength, nil
+}
+
+// probePlaylist reads the HLS playlist into probe and returns the bandwidth
+// of the variant it followed. Of a master playlist the highest bandwidth
+// variant is used.
+func probePlaylist(client *http.Client, urls []string, probe *FormatProbe) (int64, error) {
+	resp, err := OpenAlternatives(client, urls, nil, int64(len("#EXTM3U")))
+	if err != nil {
+		return 0, err
+	}
+	playlist, listType, err := m3u8.DecodeFrom(resp.Body, true)
+	resp.Body.Close()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", resp.Request.URL, err)
+	}
+
+	switch listType {
+	case m3u8.MASTER:
+		var best *m3u8.Variant
+		for _, variant := range playlist.(*m3u8.MasterPlaylist).Variants {
+			if variant != nil && (best == nil || variant.Bandwidth > best.Bandwidth) {
+				best = variant
+			}
+		}
+		if best == nil {
+			return 0, fmt.Errorf("%s: no variants in master playlist", resp.Request.URL)
+		}
+		if w, h, found := strings.Cut(best.Resolution, "x"); found {
+			probe.Width, probe.Height = parseInt(w), parseInt(h)
+		}
+		ref, err := resp.Request.URL.Parse(best.URI)
+		if err != nil {
+			return 0, err
+		}
+		_, err = probePlaylist(client, []string{ref.String()}, probe)
+		return int64(best.Bandwidth), err
+	case m3u8.MEDIA:
+		var total float64
+		for _, segment := range playlist.(*m3u8.MediaPlaylist).Segments {
+			if segment != nil {
+				total += segment.Duration
+			}
+		}
+		probe.Duration = time.Duration(total * float64(time.Second)).Round(time.Millisecond)
+	}
+	return 0, nil
+}
@@ -0,0 +1,75 @@
+package hdrezka
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStreamProbe(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/720.mp4":
+			if req.Header.Get("Range") != "bytes=0-0" {
+				t.Errorf("MP4 requested with Range %q", req.Header.Get("Range"))
+			}
+			w.Header().Set("Content-Range", "bytes 0-0/1000000")
+			w.WriteHeader(http.StatusPartialContent)
+			io.WriteString(w, "x")
+		case "/720.mp4:hls:manifest.m3u8":
+			io.WriteString(w, "#EXTM3U\n"+
+				"#EXT-X-STREAM-INF:BANDWIDTH=300000,RESOLUTION=640x360\nlow/index.m3u8\n"+
+				"#EXT-X-STREAM-INF:BANDWIDTH=500000,RESOLUTION=1280x720\nhigh/index.m3u8\n")
+		case "/high/index.m3u8":
+			io.WriteString(w, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:10\n#EXT-X-MEDIA-SEQUENCE:0\n"+
+				"#EXTINF:10.000,\nseg0.ts\n#EXTINF:10.000,\nseg1.ts\n#EXT-X-ENDLIST\n")
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer srv.Close()
+
+	stream := &Stream{
+		r: &HDRezka{Client: srv.Client()},
+		Formats: map[string]VideoFormat{
+			"720p": {
+				MP4URLs: []string{srv.URL + "/720.mp4"},
+				HLSURLs: []string{srv.URL + "/720.mp4:hls:manifest.m3u8"},
+			},
+			"1080p": {
+				MP4URLs: []string{srv.URL + "/missing.mp4"},
+				HLSURLs: []string{srv.URL + "/missing.m3u8"},
+			},
+		},
+	}
+	for label, format := range stream.Formats {
+		format.MP4, format.HLS = format.MP4URLs[0], format.HLSURLs[0]
+		stream.Formats[label] = format
+	}
+
+	probes := stream.Probe()
+	if len(probes) != 2 || probes[0].Quality.Label != "720p" || probes[1].Quality.Label != "1080p" {
+		t.Fatalf("Probe() = %+v, want 720p and 1080p in quality order", probes)
+	}
+
+	got := probes[0]
+	want := FormatProbe{
+		Quality:  ParseQuality("720p"),
+		Size:     1000000,
+		Bitrate:  400000,
+		Width:    1280,
+		Height:   720,
+		Duration: 20 * time.Second,
+	}
+	if got != want {
+		t.Errorf("Probe()[720p] = %+v, want %+v", got, want)
+	}
+
+	if probes[1].Err == nil || probes[1].Size != -1 {
+		t.Errorf("Probe()[1080p] = %+v, want an error and unknown size", probes[1])
+	}
+}
@@ -0,0 +1,151 @@
+package hdrezka
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var reHeight = regexp.MustCompile(`(?i)(\d+)\s*p\b|(\d)k\b`)
+
+// Quality is a parsed stream quality label such as "720p" or "1080p Ultra".
+type Quality struct {
+	// Label is the key of Stream.Formats.
+	Label  string `json:"label"`
+	Height int    `json:"height"`
+	// Ultra marks the premium high-bitrate variant of a resolution.
+	Ultra bool `json:"ultra,omitempty"`
+}
+
+// ParseQuality parses a quality label. "2K" and "4K" map to 1440 and 2160
+// lines; an unrecognised label has zero Height.
+func ParseQuality(label string) Quality {
+	q := Quality{Label: label}
+	if m := reHeight.FindStringSubmatch(label); m != nil {
+		if m[1] != "" {
+			q.Height = parseInt(m[1])
+		} else if m[2] == "2" {
+			q.Height = 1440
+		} else if m[2] == "4" {
+			q.Height = 2160
+		}
+	}
+	lower := strings.ToLower(label)
+	q.Ultra = strings.Contains(lower, "ultra") || strings.Contains(lower, "premium")
+	return q
+}
+
+// Compare orders qualities by height, then plain before Ultra.
+func (q Quality) Compare(other Quality) int {
+	if c := cmp.Compare(q.Height, other.Height); c != 0 {
+		return c
+	}
+	switch {
+	case q.Ultra == other.Ultra:
+		return 0
+	case q.Ultra:
+		return 1
+	}
+	return -1
+}
+
+func (q Quality) String() string {
+	return q.Label
+}
+
+// Qualities returns the available qualities from worst to best. Formats
+// without any URL are skipped.
+func (s *Stream) Qualities() []Quality {
+	qualities := make([]Quality, 0, len(s.Formats))
+	for label, format := range s.Formats {
+		if format.HLS != "" || format.MP4 != "" {
+			qualities = append(qualities, ParseQuality(label))
+		}
+	}
+	slices.SortFunc(qualities, func(a, b Quality) int {
+		if c := a.Compare(b); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Label, b.Label)
+	})
+	return qualities
+}
+
+// SortedFormats iterates the available formats from worst to best quality.
+func (s *Stream) SortedFormats() iter.Seq2[Quality, VideoFormat] {
+	return func(yield func(Quality, VideoFormat) bool) {
+		for _, q := range s.Qualities() {
+			if !yield(q, s.Formats[q.Label]) {
+				return
+			}
+		}
+	}
+}
+
+// CheckQualitySpec reports the first preference of a Pick spec that is
+// neither best, worst nor a quality, so a typo is not taken for a quality
+// the stream lacks.
+func CheckQualitySpec(spec string) error {
+	for _, pref := range strings.Split(spec, ",") {
+		pref = strings.TrimSpace(pref)
+		if strings.EqualFold(pref, "best") || strings.EqualFold(pref, "worst") {
+			continue
+		}
+		if ParseQuality(strings.TrimSpace(strings.TrimPrefix(pref, "<="))).Height == 0 {
+			return fmt.Errorf("invalid quality %q", pref)
+		}
+	}
+	return nil
+}
+
+// Pick selects a format by a quality spec. A spec is a comma-separated list
+// of preferences tried in order:
+//
+//	best, worst   the highest / lowest available quality
+//	<=720p        the best quality not above 720p ("<=1080p Ultra" allows Ultra)
+//	1080p Ultra   exactly this quality
+//
+// For example "1080p,720p" or "<=720p,worst".
+func (s *Stream) Pick(spec string) (Quality, VideoFormat, error) {
+	if err := CheckQualitySpec(spec); err != nil {
+		return Quality{}, VideoFormat{}, err
+	}
+	qualities := s.Qualities()
+	if len(qualities) == 0 {
+		return Quality{}, VideoFormat{}, fmt.Errorf("stream has no formats")
+	}
+	for _, pref := range strings.Split(spec, ",") {
+		pref = strings.TrimSpace(pref)
+		var (
+			q     Quality
+			found bool
+		)
+		switch {
+		case strings.EqualFold(pref, "best"):
+			q, found = qualities[len(qualities)-1], true
+		case strings.EqualFold(pref, "worst"):
+			q, found = qualities[0], true
+		case strings.HasPrefix(pref, "<="):
+			limit := ParseQuality(strings.TrimSpace(pref[2:]))
+			for i := len(qualities) - 1; i >= 0 && !found; i-- {
+				if qualities[i].Compare(limit) <= 0 {
+					q, found = qualities[i], true
+				}
+			}
+		default:
+			want := ParseQuality(pref)
+			for _, candidate := range qualities {
+				if candidate.Compare(want) == 0 {
+					q, found = candidate, true
+				}
+			}
+		}
+		if found {
+			return q, s.Formats[q.Label], nil
+		}
+	}
+	return Quality{}, VideoFormat{}, fmt.Errorf("quality %s not found", spec)
+}
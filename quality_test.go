@@ -0,0 +1,101 @@
+package hdrezka
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuality(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		label  string
+		height int
+		ultra  bool
+	}{
+		{"360p", 360, false},
+		{"1080p Ultra", 1080, true},
+		{"2K", 1440, false},
+		{"4K", 2160, false},
+		{"CAMRip", 0, false},
+	}
+	for _, tt := range tests {
+		q := ParseQuality(tt.label)
+		if q.Height != tt.height || q.Ultra != tt.ultra || q.Label != tt.label {
+			t.Errorf("ParseQuality(%q) = %+v, want height %d, ultra %v", tt.label, q, tt.height, tt.ultra)
+		}
+	}
+}
+
+func TestStreamPick(t *testing.T) {
+	t.Parallel()
+
+	stream := &Stream{Formats: map[string]VideoFormat{
+		"360p":        {MP4: "http://a/360.mp4"},
+		"720p":        {MP4: "http://a/720.mp4"},
+		"1080p":       {MP4: "http://a/1080.mp4"},
+		"1080p Ultra": {MP4: "http://a/1080u.mp4"},
+		"4K":          {},
+	}}
+
+	var order []string
+	for q := range stream.SortedFormats() {
+		order = append(order, q.Label)
+	}
+	if got, want := len(order), 4; got != want || order[0] != "360p" || order[3] != "1080p Ultra" {
+		t.Errorf("SortedFormats() order = %v, want 360p..1080p Ultra without empty 4K", order)
+	}
+
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{"best", "1080p Ultra", false},
+		{"worst", "360p", false},
+		{"<=1080p", "1080p", false},
+		{"<=1080p Ultra", "1080p Ultra", false},
+		{"<=480p", "360p", false},
+		{"<=240p,best", "1080p Ultra", false},
+		{"480p,720p", "720p", false},
+		{"1080P", "1080p", false},
+		{"480p", "", true},
+		{"<=fast", "", true},
+		{"72Op,best", "", true},
+	}
+	for _, tt := range tests {
+		q, format, err := stream.Pick(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Pick(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if q.Label != tt.want {
+			t.Errorf("Pick(%q) = %q, want %q", tt.spec, q.Label, tt.want)
+		}
+		if !tt.wantErr && !reflect.DeepEqual(format, stream.Formats[tt.want]) {
+			t.Errorf("Pick(%q) format = %+v, want %+v", tt.spec, format, stream.Formats[tt.want])
+		}
+	}
+}
+
+func TestCheckQualitySpec(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{"best", false},
+		{"Worst", false},
+		{"<=720p, 1080p Ultra,4K", false},
+		{"72Op", true},
+		{"1080p,", true},
+		{"<=", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		if err := CheckQualitySpec(tt.spec); (err != nil) != tt.wantErr {
+			t.Errorf("CheckQualitySpec(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package hdrezka
+
+import (
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ScheduleItem is an episode of the release schedule shown on series pages.
+type ScheduleItem struct {
+	Season        int       `json:"season"`
+	Episode       int       `json:"episode"`
+	Title         string    `json:"title,omitempty"`
+	TitleOriginal string    `json:"title_original,omitempty"`
+	Released      time.Time `json:"released,omitzero"`
+}
+
+// ScheduledEpisode returns the schedule entry of an episode.
+func (video *Video) ScheduledEpisode(season, episode int) (ScheduleItem, bool) {
+	for _, item := range video.Schedule {
+		if item.Season == season && item.Episode == episode {
+			return item, true
+		}
+	}
+	return ScheduleItem{}, false
+}
+
+// parseSchedule reads the ".b-post__schedule" table rows such as
+// "1 сезон 2 серия | <b>Название</b><span>Title</span> | 27 января 2008".
+// Rows announcing episodes without a season or episode number are skipped.
+func parseSchedule(doc *goquery.Document) []ScheduleItem {
+	var schedule []ScheduleItem
+	doc.Find(".b-post__schedule_table tr").Each(func(i int, s *goquery.Selection) {
+		number := s.Find("td.td-1").Text()
+		season, episode := reStatusSeason.FindStringSubmatch(number), reStatusEpisode.FindStringSubmatch(number)
+		if season == nil || episode == nil {
+			return
+		}
+		title := s.Find("td.td-2")
+		schedule = append(schedule, ScheduleItem{
+			Season:        parseInt(season[1]),
+			Episode:       parseInt(episode[1]),
+			Title:         strings.TrimSpace(title.Find("b").Text()),
+			TitleOriginal: strings.TrimSpace(title.Find("span").Text()),
+			Released:      parseReleaseDate(s.Find("td.td-4").Text()),
+		})
+	})
+	return schedule
+}
@@ -0,0 +1,55 @@
+package hdrezka
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const scheduleFixture = `<div class="b-post__schedule">
+<div class="b-post__schedule_block" id="season-1">
+	<div class="b-post__schedule_list">
+	<table class="b-post__schedule_table"><tbody>
+		<tr><td class="td-1" data-id="646" data-season_id="1" data-episode_id="2">1 сезон 2 серия</td><td class="td-2"><b>Кот в мешке</b> <span>Cat's in the Bag...</span></td><td class="td-3"><i class="watch-episode-action"></i></td><td class="td-4">27 января 2008</td><td class="td-5">&#10003;</td></tr>
+		<tr><td class="td-1">1 сезон 1 серия</td><td class="td-2"><b>Пилот</b></td><td class="td-4">20 января 2008</td></tr>
+		<tr><td colspan="5" class="load-more">Показать еще</td></tr>
+	</tbody></table>
+	</div>
+</div>
+</div>`
+
+func TestParseSchedule(t *testing.T) {
+	t.Parallel()
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(scheduleFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	video := &Video{Schedule: parseSchedule(doc)}
+	want := []ScheduleItem{
+		{Season: 1, Episode: 2, Title: "Кот в мешке", TitleOriginal: "Cat's in the Bag...", Released: time.Date(2008, time.January, 27, 0, 0, 0, 0, time.UTC)},
+		{Season: 1, Episode: 1, Title: "Пилот", Released: time.Date(2008, time.January, 20, 0, 0, 0, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(video.Schedule, want) {
+		t.Errorf("parseSchedule() = %+v, want %+v", video.Schedule, want)
+	}
+
+	tests := []struct {
+		season, episode int
+		want            string
+		found           bool
+	}{
+		{1, 1, "Пилот", true},
+		{1, 2, "Кот в мешке", true},
+		{2, 1, "", false},
+	}
+	for _, tt := range tests {
+		item, found := video.ScheduledEpisode(tt.season, tt.episode)
+		if item.Title != tt.want || found != tt.found {
+			t.Errorf("ScheduledEpisode(%d, %d) = %q, %v, want %q, %v", tt.season, tt.episode, item.Title, found, tt.want, tt.found)
+		}
+	}
+}
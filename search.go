@@ -1,6 +1,12 @@
 package hdrezka
 
-import "github.com/PuerkitoBio/goquery"
+import (
+	"iter"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
 
 // QuickSearch simple search for videos by query.
 func (r *HDRezka) QuickSearch(query string) ([]*CoverItem, error) {
@@ -20,12 +26,19 @@ func (r *HDRezka) QuickSearch(query string) ([]*CoverItem, error) {
 		link := s.Find("a")
 		rating := s.Find("span.rating").Text()
 		s.Find("span.rating").Remove()
-		items = append(items, &CoverItem{
+		item := &CoverItem{
 			Description: link.Text(),
 			Info:        rating,
 			Title:       s.Find("span.enty").Text(),
 			URL:         link.AttrOr("href", ""),
-		})
+		}
+		item.ID = videoIDFromURL(item.URL)
+		item.Type = genreFromURL(item.URL)
+		item.Rating.Score = parseFloat(strings.Trim(strings.TrimSpace(rating), "()"))
+		if years := reYear.FindAllString(item.Description, -1); len(years) > 0 {
+			item.Year = parseInt(years[len(years)-1])
+		}
+		items = append(items, item)
 	})
 
 	return items, nil
@@ -33,6 +46,16 @@ func (r *HDRezka) QuickSearch(query string) ([]*CoverItem, error) {
 
 // Search search for videos by query.
 func (r *HDRezka) Search(query string, maxItems int) ([]*CoverItem, error) {
+	return r.getItems(r.searchURL(query).String(), maxItems)
+}
+
+// SearchAll lazily iterates every search result for query, fetching result
+// pages only as the loop consumes them.
+func (r *HDRezka) SearchAll(query string) iter.Seq2[*CoverItem, error] {
+	return r.iterItems(r.searchURL(query).String())
+}
+
+func (r *HDRezka) searchURL(query string) *url.URL {
 	searchURL := r.URL.JoinPath("/search/")
 
 	q := searchURL.Query()
@@ -41,5 +64,5 @@ func (r *HDRezka) Search(query string, maxItems int) ([]*CoverItem, error) {
 	q.Set("q", query)
 	searchURL.RawQuery = q.Encode()
 
-	return r.getItems(searchURL.String(), maxItems)
+	return searchURL
 }
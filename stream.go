@@ -1,30 +1,60 @@
 package hdrezka
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/url"
 	"strconv"
+	"time"
 )
 
+// expiryParams are the query parameters signed CDN links carry their
+// expiry in, as Unix seconds.
+var expiryParams = []string{"expires", "exp", "e"}
+
 // VideoFormat of stream
 type VideoFormat struct {
 	HLS string `json:"hls"`
 	MP4 string `json:"mp4"`
+	// HLSURLs and MP4URLs hold every CDN alternative for the quality, most
+	// preferred first; HLS and MP4 are their first entries. Pass them to
+	// OpenAlternatives to fail over to a backup node.
+	HLSURLs []string `json:"hls_urls,omitempty"`
+	MP4URLs []string `json:"mp4_urls,omitempty"`
 }
 
 // Stream is a struct for stream info
 type Stream struct {
-	Formats     map[string]VideoFormat
-	Subtitles   map[string]string
-	Subtitle    any    `json:"subtitle"`
-	SubtitleDef any    `json:"subtitle_def"`
-	Thumbnails  string `json:"thumbnails"`
-	URL         string `json:"url"`
+	r *HDRezka
+	// translation, season and episode identify the stream for Refresh.
+	translation     *Translation
+	season, episode int
+	Formats         map[string]VideoFormat
+	Subtitles       map[string]string
+	// SubtitleTracks lists Subtitles in player order with ISO 639-1 codes
+	// and the default flag.
+	SubtitleTracks []SubtitleTrack
+	Subtitle       any    `json:"subtitle"`
+	SubtitleDef    any    `json:"subtitle_def"`
+	SubtitleLns    any    `json:"subtitle_lns"`
+	Thumbnails     string `json:"thumbnails"`
+	URL            string `json:"url"`
+	// FetchedAt is when the links were received from the site.
+	FetchedAt time.Time `json:"fetched_at,omitzero"`
+	// ExpiresAt is the earliest expiry signed into the links, or zero when
+	// the links do not carry one.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
 }
 
 // GetStream get stream for video.
 // No parameters GetStream() for films, choose GetStream(season, episodes) for series.
 func (t *Translation) GetStream(season_episode ...int) (*Stream, error) {
+	return t.GetStreamContext(context.Background(), season_episode...)
+}
+
+// GetStreamContext is GetStream with a context that cancels the request.
+func (t *Translation) GetStreamContext(ctx context.Context, season_episode ...int) (*Stream, error) {
 	var season, episode int
 	if len(season_episode) > 0 {
 		if len(season_episode) == 2 {
@@ -55,14 +85,15 @@ func (t *Translation) GetStream(season_episode ...int) (*Stream, error) {
 		}
 	}
 
-	var stream Stream
-	err := t.r.getCDN(form, &stream)
+	stream := Stream{r: t.r, translation: t, season: season, episode: episode}
+	err := t.r.getCDNContext(ctx, form, &stream)
 	if err != nil {
 		return nil, err
 	}
 
 	if subtitleStr, ok := stream.Subtitle.(string); ok && subtitleStr != "" {
 		stream.Subtitles = parseSubtitles(subtitleStr)
+		stream.SubtitleTracks = parseSubtitleTracks(subtitleStr, stream.SubtitleLns, stream.SubtitleDef)
 	}
 
 	stream.URL, err = decodeURL(stream.URL)
@@ -70,6 +101,8 @@ func (t *Translation) GetStream(season_episode ...int) (*Stream, error) {
 		return nil, err
 	}
 	stream.Formats = parseStreamFormats(stream.URL)
+	stream.FetchedAt = time.Now()
+	stream.ExpiresAt = streamExpiry(stream.Formats)
 
 	if stream.Thumbnails != "" {
 		thumbURL, err := url.QueryUnescape(t.r.URL.JoinPath(stream.Thumbnails).String())
@@ -79,3 +112,65 @@ func (t *Translation) GetStream(season_episode ...int) (*Stream, error) {
 	}
 	return &stream, nil
 }
+
+// Expired reports whether the links are past their signed expiry. Links
+// without an expiry never report expired, but the CDN may still reject them
+// with 403 or 410 after a few hours; call Refresh then.
+func (s *Stream) Expired() bool {
+	return !s.ExpiresAt.IsZero() && time.Now().After(s.ExpiresAt)
+}
+
+// Refresh requests fresh links for the same translation, season and episode
+// and replaces the stream contents with them.
+func (s *Stream) Refresh() error {
+	return s.RefreshContext(context.Background())
+}
+
+// RefreshContext is Refresh with a context that cancels the request.
+func (s *Stream) RefreshContext(ctx context.Context) error {
+	if s.translation == nil {
+		return errors.New("stream cannot be refreshed: unknown translation or episode")
+	}
+	var (
+		fresh *Stream
+		err   error
+	)
+	if s.season > 0 {
+		fresh, err = s.translation.GetStreamContext(ctx, s.season, s.episode)
+	} else {
+		fresh, err = s.translation.GetStreamContext(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	*s = *fresh
+	return nil
+}
+
+// streamExpiry returns the earliest expiry found in the format links.
+func streamExpiry(formats map[string]VideoFormat) time.Time {
+	var earliest time.Time
+	for _, format := range formats {
+		for _, u := range append(format.MP4URLs, format.HLSURLs...) {
+			if exp := urlExpiry(u); !exp.IsZero() && (earliest.IsZero() || exp.Before(earliest)) {
+				earliest = exp
+			}
+		}
+	}
+	return earliest
+}
+
+// urlExpiry parses the expiry query parameter of a signed link.
+func urlExpiry(rawURL string) time.Time {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return time.Time{}
+	}
+	query := u.Query()
+	for _, param := range expiryParams {
+		if sec, err := strconv.ParseInt(query.Get(param), 10, 64); err == nil && sec > 0 {
+			return time.Unix(sec, 0)
+		}
+	}
+	return time.Time{}
+}
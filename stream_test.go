@@ -0,0 +1,98 @@
+package hdrezka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestURLExpiry(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		url  string
+		want int64
+	}{
+		{"https://cdn.example.com/a.mp4?expires=1700000000&sig=x", 1700000000},
+		{"https://cdn.example.com/a.mp4?e=1700000001", 1700000001},
+		{"https://cdn.example.com/a.mp4:hls:manifest.m3u8?exp=1700000002", 1700000002},
+		{"https://cdn.example.com/a.mp4", 0},
+		{"https://cdn.example.com/a.mp4?expires=soon", 0},
+	}
+	for _, tt := range tests {
+		got := urlExpiry(tt.url)
+		if tt.want == 0 && !got.IsZero() || tt.want != 0 && got.Unix() != tt.want {
+			t.Errorf("urlExpiry(%q) = %v, want %d", tt.url, got, tt.want)
+		}
+	}
+
+	formats := parseStreamFormats("[480p]https://a/480.mp4?expires=1700000500,[720p]https://a/720.mp4?expires=1700000100 or https://b/720.mp4")
+	if got := streamExpiry(formats); got.Unix() != 1700000100 {
+		t.Errorf("streamExpiry() = %v, want the earliest link expiry", got)
+	}
+}
+
+func TestStreamRefresh(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/ajax/get_cdn_series/" || req.FormValue("action") != "get_stream" ||
+			req.FormValue("season") != "2" || req.FormValue("episode") != "5" {
+			http.NotFound(w, req)
+			return
+		}
+		n := calls.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": true,
+			"url":     fmt.Sprintf("[720p]https://cdn.example.com/%d.mp4?expires=%d", n, 1700000000+n),
+		})
+	}))
+	defer srv.Close()
+
+	r := New()
+	r.URL, _ = url.Parse(srv.URL)
+	tr := &Translation{r: r, videoID: "1", ID: "56"}
+
+	before := time.Now()
+	stream, err := tr.GetStream(2, 5)
+	if err != nil {
+		t.Fatalf("GetStream() error = %v", err)
+	}
+	if stream.FetchedAt.Before(before) || stream.ExpiresAt.Unix() != 1700000001 {
+		t.Errorf("GetStream() FetchedAt = %v, ExpiresAt = %v", stream.FetchedAt, stream.ExpiresAt)
+	}
+	if !stream.Expired() {
+		t.Error("Expired() = false for a link that expired in 2023")
+	}
+
+	if err := stream.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if got := stream.Formats["720p"].MP4; got != "https://cdn.example.com/2.mp4?expires=1700000002" {
+		t.Errorf("Refresh() MP4 = %q, want the second link", got)
+	}
+	if stream.ExpiresAt.Unix() != 1700000002 {
+		t.Errorf("Refresh() ExpiresAt = %v, want the new expiry", stream.ExpiresAt)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := stream.RefreshContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("RefreshContext() with a canceled context error = %v", err)
+	}
+	if got := stream.Formats["720p"].MP4; got != "https://cdn.example.com/2.mp4?expires=1700000002" {
+		t.Errorf("failed RefreshContext() replaced the stream with %q", got)
+	}
+
+	if err := (&Stream{}).Refresh(); err == nil {
+		t.Error("Refresh() of a stream without translation returned no error")
+	}
+}
@@ -0,0 +1,277 @@
+// Package subtitles fetches HDrezka subtitle tracks and converts them
+// between WebVTT, SRT and ASS.
+package subtitles
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/n0madic/go-hdrezka/internal/vtt"
+)
+
+// Format is a subtitle file format.
+type Format string
+
+const (
+	ASS Format = "ass"
+	SRT Format = "srt"
+	VTT Format = "vtt"
+)
+
+// ParseFormat validates a format name such as "srt".
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimPrefix(name, "."))); f {
+	case ASS, SRT, VTT:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown subtitle format %q (want ass, srt or vtt)", name)
+}
+
+// Cue is a single subtitle entry. Text lines are separated by "\n".
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// Track is a list of cues ordered by start time.
+type Track []Cue
+
+var (
+	reTag     = regexp.MustCompile(`</?[^>]+>`)
+	reKeepTag = regexp.MustCompile(`^</?[ibu]>$`)
+)
+
+// Fetch downloads a WebVTT track with client (pass HDRezka.Client so the
+// session cookies are sent) and parses it.
+func Fetch(client *http.Client, url string) (Track, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
+	}
+	return ParseVTT(resp.Body)
+}
+
+// ParseVTT parses a WebVTT document. Cue identifiers, cue settings and
+// NOTE / STYLE / REGION blocks are dropped.
+func ParseVTT(r io.Reader) (Track, error) {
+	var (
+		track   Track
+		cue     *Cue
+		skip    bool
+		scanner = bufio.NewScanner(r)
+	)
+	flush := func() {
+		if cue != nil {
+			cue.Text = strings.TrimRight(cue.Text, "\n")
+			track = append(track, *cue)
+			cue = nil
+		}
+	}
+	for lineNo := 0; scanner.Scan(); lineNo++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if lineNo == 0 {
+			line = strings.TrimPrefix(line, "\ufeff")
+			if !strings.HasPrefix(line, "WEBVTT") {
+				return nil, errors.New("not a WebVTT document")
+			}
+			skip = true
+			continue
+		}
+		switch {
+		case line == "":
+			flush()
+			skip = false
+		case skip:
+		case cue != nil:
+			cue.Text += line + "\n"
+		case strings.Contains(line, "-->"):
+			startStr, endStr, _ := strings.Cut(line, "-->")
+			start, err := vtt.ParseTimestamp(startStr)
+			if err != nil {
+				return nil, err
+			}
+			end, err := vtt.ParseTimestamp(endStr)
+			if err != nil {
+				return nil, err
+			}
+			cue = &Cue{Start: start, End: end}
+		case strings.HasPrefix(line, "NOTE"), strings.HasPrefix(line, "STYLE"), strings.HasPrefix(line, "REGION"):
+			skip = true
+		}
+		// Any other line outside a cue is a cue identifier.
+	}
+	flush()
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(track, func(i, j int) bool { return track[i].Start < track[j].Start })
+	return track, nil
+}
+
+// Shift moves every cue by d. Cues pushed entirely before zero are dropped
+// and cues straddling zero are clipped.
+func (t Track) Shift(d time.Duration) Track {
+	shifted := make(Track, 0, len(t))
+	for _, cue := range t {
+		cue.Start += d
+		cue.End += d
+		if cue.End <= 0 {
+			continue
+		}
+		cue.Start = max(cue.Start, 0)
+		shifted = append(shifted, cue)
+	}
+	return shifted
+}
+
+// Merge builds a dual-language track. Each primary cue keeps its timing and
+// gets the text of every overlapping secondary cue appended on a new line.
+// Secondary cues that overlap no primary cue are kept as they are.
+func Merge(primary, secondary Track) Track {
+	merged := make(Track, 0, len(primary)+len(secondary))
+	used := make([]bool, len(secondary))
+	for _, cue := range primary {
+		for i, other := range secondary {
+			if other.Start < cue.End && cue.Start < other.End {
+				cue.Text += "\n" + other.Text
+				used[i] = true
+			}
+		}
+		merged = append(merged, cue)
+	}
+	for i, other := range secondary {
+		if !used[i] {
+			merged = append(merged, other)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Start < merged[j].Start })
+	return merged
+}
+
+// Write encodes the track in the given format.
+func (t Track) Write(w io.Writer, format Format) error {
+	switch format {
+	case ASS:
+		return t.WriteASS(w)
+	case SRT:
+		return t.WriteSRT(w)
+	case VTT:
+		return t.WriteVTT(w)
+	}
+	return fmt.Errorf("unknown subtitle format %q", format)
+}
+
+// WriteVTT encodes the track as WebVTT.
+func (t Track) WriteVTT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("WEBVTT\n")
+	for _, cue := range t {
+		fmt.Fprintf(bw, "\n%s --> %s\n%s\n", formatTimestamp(cue.Start, "."), formatTimestamp(cue.End, "."), cue.Text)
+	}
+	return bw.Flush()
+}
+
+// WriteSRT encodes the track as SubRip. Only the <i>, <b> and <u> tags
+// SRT players understand are kept, and WebVTT entities such as &amp; are
+// decoded.
+func (t Track) WriteSRT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for i, cue := range t {
+		text := convertText(cue.Text, html.UnescapeString, func(tag string) string {
+			if reKeepTag.MatchString(tag) {
+				return tag
+			}
+			return ""
+		})
+		fmt.Fprintf(bw, "%d\n%s --> %s\n%s\n\n", i+1, formatTimestamp(cue.Start, ","), formatTimestamp(cue.End, ","), text)
+	}
+	return bw.Flush()
+}
+
+// assHeader defines a single bottom-centred style used by every event.
+const assHeader = `[Script Info]
+ScriptType: v4.00+
+WrapStyle: 0
+ScaledBorderAndShadow: yes
+PlayResX: 1920
+PlayResY: 1080
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,Arial,64,&H00FFFFFF,&H000000FF,&H00000000,&H80000000,0,0,0,0,100,100,0,0,1,3,1,2,60,60,50,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+`
+
+// WriteASS encodes the track as Advanced SubStation Alpha. Italic, bold
+// and underline tags become override codes; other tags are dropped.
+// Entities are decoded and the "\", "{" and "}" ASS reads as codes are
+// escaped.
+func (t Track) WriteASS(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(assHeader)
+	for _, cue := range t {
+		text := convertText(cue.Text, escapeASS, func(tag string) string {
+			if !reKeepTag.MatchString(tag) {
+				return ""
+			}
+			state := "1"
+			if strings.HasPrefix(tag, "</") {
+				state = "0"
+			}
+			return `{\` + strings.Trim(tag, "</>") + state + "}"
+		})
+		text = strings.ReplaceAll(text, "\n", `\N`)
+		fmt.Fprintf(bw, "Dialogue: 0,%s,%s,Default,,0,0,0,,%s\n", formatASSTimestamp(cue.Start), formatASSTimestamp(cue.End), text)
+	}
+	return bw.Flush()
+}
+
+// convertText rewrites WebVTT cue text for another format, passing the
+// text between tags through text and every tag through tag.
+func convertText(s string, text, tag func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range reTag.FindAllStringIndex(s, -1) {
+		b.WriteString(text(s[last:loc[0]]))
+		b.WriteString(tag(s[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	b.WriteString(text(s[last:]))
+	return b.String()
+}
+
+var assEscaper = strings.NewReplacer(`\`, `\\`, "{", `\{`, "}", `\}`)
+
+// escapeASS decodes the entities of WebVTT text and escapes the characters
+// ASS would read as override blocks or codes.
+func escapeASS(s string) string {
+	return assEscaper.Replace(html.UnescapeString(s))
+}
+
+// formatTimestamp renders hh:mm:ss<sep>ttt as used by WebVTT and SRT.
+func formatTimestamp(d time.Duration, sep string) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// formatASSTimestamp renders h:mm:ss.cc as used by ASS.
+func formatASSTimestamp(d time.Duration) string {
+	cs := d.Milliseconds() / 10
+	return fmt.Sprintf("%d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
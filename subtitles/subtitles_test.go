@@ -0,0 +1,128 @@
+package subtitles
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleVTT = "\ufeffWEBVTT\nKind: captions\n\nNOTE written by hand\nspanning lines\n\n1\n00:00:01.000 --> 00:00:02.500 align:center\n<i>Hello</i>, <c.yellow>world</c>\n\n00:03.000 --> 00:04.000\nSecond\nline\n"
+
+func TestParseVTT(t *testing.T) {
+	t.Parallel()
+
+	track, err := ParseVTT(strings.NewReader(sampleVTT))
+	if err != nil {
+		t.Fatalf("ParseVTT() error = %v", err)
+	}
+	want := Track{
+		{time.Second, 2500 * time.Millisecond, "<i>Hello</i>, <c.yellow>world</c>"},
+		{3 * time.Second, 4 * time.Second, "Second\nline"},
+	}
+	if len(track) != len(want) {
+		t.Fatalf("ParseVTT() = %+v, want %+v", track, want)
+	}
+	for i := range want {
+		if track[i] != want[i] {
+			t.Errorf("cue[%d] = %+v, want %+v", i, track[i], want[i])
+		}
+	}
+
+	if _, err := ParseVTT(strings.NewReader("1\n00:00:01,000 --> 00:00:02,000\nSRT\n")); err == nil {
+		t.Error("ParseVTT() accepted a document without the WEBVTT header")
+	}
+}
+
+func TestTrackWrite(t *testing.T) {
+	t.Parallel()
+
+	track, err := ParseVTT(strings.NewReader(sampleVTT))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{SRT, "1\n00:00:01,000 --> 00:00:02,500\n<i>Hello</i>, world\n\n2\n00:00:03,000 --> 00:00:04,000\nSecond\nline\n\n"},
+		{VTT, "WEBVTT\n\n00:00:01.000 --> 00:00:02.500\n<i>Hello</i>, <c.yellow>world</c>\n\n00:00:03.000 --> 00:00:04.000\nSecond\nline\n"},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := track.Write(&b, tt.format); err != nil {
+			t.Fatalf("Write(%s) error = %v", tt.format, err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("Write(%s) = %q, want %q", tt.format, b.String(), tt.want)
+		}
+	}
+
+	var b strings.Builder
+	if err := track.WriteASS(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`Dialogue: 0,0:00:01.00,0:00:02.50,Default,,0,0,0,,{\i1}Hello{\i0}, world`,
+		`Dialogue: 0,0:00:03.00,0:00:04.00,Default,,0,0,0,,Second\Nline`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("WriteASS() output is missing %q", line)
+		}
+	}
+}
+
+func TestShiftAndMerge(t *testing.T) {
+	t.Parallel()
+
+	track := Track{
+		{0, time.Second, "dropped"},
+		{time.Second, 3 * time.Second, "clipped"},
+		{4 * time.Second, 5 * time.Second, "moved"},
+	}
+	shifted := track.Shift(-2 * time.Second)
+	want := Track{
+		{0, time.Second, "clipped"},
+		{2 * time.Second, 3 * time.Second, "moved"},
+	}
+	if len(shifted) != len(want) || shifted[0] != want[0] || shifted[1] != want[1] {
+		t.Errorf("Shift() = %+v, want %+v", shifted, want)
+	}
+
+	primary := Track{{time.Second, 3 * time.Second, "Привет"}}
+	secondary := Track{
+		{1500 * time.Millisecond, 2500 * time.Millisecond, "Hello"},
+		{5 * time.Second, 6 * time.Second, "Alone"},
+	}
+	merged := Merge(primary, secondary)
+	wantMerged := Track{
+		{time.Second, 3 * time.Second, "Привет\nHello"},
+		{5 * time.Second, 6 * time.Second, "Alone"},
+	}
+	if len(merged) != len(wantMerged) || merged[0] != wantMerged[0] || merged[1] != wantMerged[1] {
+		t.Errorf("Merge() = %+v, want %+v", merged, wantMerged)
+	}
+}
+
+func TestTrackWriteEscaping(t *testing.T) {
+	t.Parallel()
+
+	track := Track{{Start: time.Second, End: 2 * time.Second, Text: `<i>Tom &amp; Jerry</i> &lt;3 {\an8} C:\dir`}}
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{SRT, "<i>Tom & Jerry</i> <3 {\\an8} C:\\dir\n"},
+		{ASS, `Dialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,{\i1}Tom & Jerry{\i0} <3 \{\\an8\} C:\\dir` + "\n"},
+		{VTT, "<i>Tom &amp; Jerry</i> &lt;3 {\\an8} C:\\dir\n"},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := track.Write(&b, tt.format); err != nil {
+			t.Fatalf("Write(%s) error = %v", tt.format, err)
+		}
+		if !strings.Contains(b.String(), tt.want) {
+			t.Errorf("Write(%s) = %q, want it to contain %q", tt.format, b.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package hdrezka
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg" // sprite sheets are usually JPEG
+	_ "image/png"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/n0madic/go-hdrezka/internal/vtt"
+)
+
+// ThumbnailCue is one seek-preview frame of a Stream.Thumbnails track.
+type ThumbnailCue struct {
+	Start time.Duration `json:"start"`
+	End   time.Duration `json:"end"`
+	// URL is the absolute URL of the sprite image holding the frame.
+	URL string `json:"url"`
+	// Rect is the #xywh crop box inside the sprite. It is empty when the
+	// cue refers to the whole image.
+	Rect image.Rectangle `json:"rect"`
+}
+
+// GetThumbnails downloads and parses the seek-preview WebVTT track.
+func (s *Stream) GetThumbnails() ([]ThumbnailCue, error) {
+	if s.Thumbnails == "" {
+		return nil, errors.New("stream has no thumbnails")
+	}
+	base, err := url.Parse(s.Thumbnails)
+	if err != nil {
+		return nil, err
+	}
+	body, err := s.r.getBody(s.Thumbnails)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return parseThumbnailsVTT(body, base)
+}
+
+// ThumbnailFrames downloads the sprites referenced by cues, each only once,
+// and crops every cue into its own image.
+func (s *Stream) ThumbnailFrames(cues []ThumbnailCue) ([]image.Image, error) {
+	sprites := make(map[string]image.Image)
+	frames := make([]image.Image, 0, len(cues))
+	for _, cue := range cues {
+		sprite, found := sprites[cue.URL]
+		if !found {
+			body, err := s.r.getBody(cue.URL)
+			if err != nil {
+				return nil, fmt.Errorf("sprite %s: %w", cue.URL, err)
+			}
+			sprite, _, err = image.Decode(body)
+			body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("sprite %s: %w", cue.URL, err)
+			}
+			sprites[cue.URL] = sprite
+		}
+		frames = append(frames, cropImage(sprite, cue.Rect))
+	}
+	return frames, nil
+}
+
+// ContactSheet tiles frames into a grid with the given number of columns.
+// Every cell is as large as the largest frame; frames keep their size and
+// are placed at the top-left corner of their cell.
+func ContactSheet(frames []image.Image, columns int) image.Image {
+	if len(frames) == 0 {
+		return image.NewRGBA(image.Rectangle{})
+	}
+	if columns <= 0 || columns > len(frames) {
+		columns = len(frames)
+	}
+	var cell image.Point
+	for _, frame := range frames {
+		size := frame.Bounds().Size()
+		cell.X = max(cell.X, size.X)
+		cell.Y = max(cell.Y, size.Y)
+	}
+	rows := (len(frames) + columns - 1) / columns
+	sheet := image.NewRGBA(image.Rect(0, 0, cell.X*columns, cell.Y*rows))
+	draw.Draw(sheet, sheet.Bounds(), image.Black, image.Point{}, draw.Src)
+	for i, frame := range frames {
+		origin := image.Pt(i%columns*cell.X, i/columns*cell.Y)
+		dst := image.Rectangle{Min: origin, Max: origin.Add(frame.Bounds().Size())}
+		draw.Draw(sheet, dst, frame, frame.Bounds().Min, draw.Src)
+	}
+	return sheet
+}
+
+// cropImage returns the rect part of img. An empty rect means the whole image.
+func cropImage(img image.Image, rect image.Rectangle) image.Image {
+	if rect.Empty() {
+		return img
+	}
+	rect = rect.Add(img.Bounds().Min).Intersect(img.Bounds())
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(rect)
+	}
+	cropped := image.NewRGBA(image.Rectangle{Max: rect.Size()})
+	draw.Draw(cropped, cropped.Bounds(), img, rect.Min, draw.Src)
+	return cropped
+}
+
+// parseThumbnailsVTT parses a WebVTT sprite track. Relative sprite URLs are
+// resolved against base.
+func parseThumbnailsVTT(r io.Reader, base *url.URL) ([]ThumbnailCue, error) {
+	var cues []ThumbnailCue
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		startStr, endStr, found := strings.Cut(line, "-->")
+		if !found {
+			continue
+		}
+		start, err := vtt.ParseTimestamp(startStr)
+		if err != nil {
+			return nil, err
+		}
+		end, err := vtt.ParseTimestamp(endStr)
+		if err != nil {
+			return nil, err
+		}
+		if !scanner.Scan() {
+			break
+		}
+		target, fragment, _ := strings.Cut(strings.TrimSpace(scanner.Text()), "#")
+		ref, err := url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		cue := ThumbnailCue{Start: start, End: end, URL: ref.String()}
+		if base != nil {
+			cue.URL = base.ResolveReference(ref).String()
+		}
+		if xywh, found := strings.CutPrefix(fragment, "xywh="); found {
+			cue.Rect, err = parseXYWH(xywh)
+			if err != nil {
+				return nil, err
+			}
+		}
+		cues = append(cues, cue)
+	}
+	return cues, scanner.Err()
+}
+
+// parseXYWH parses the "x,y,w,h" media fragment of a sprite URL.
+func parseXYWH(str string) (image.Rectangle, error) {
+	parts := strings.Split(str, ",")
+	if len(parts) != 4 {
+		return image.Rectangle{}, fmt.Errorf("invalid xywh fragment %q", str)
+	}
+	var n [4]int
+	for i, part := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return image.Rectangle{}, fmt.Errorf("invalid xywh fragment %q", str)
+		}
+		n[i] = v
+	}
+	return image.Rect(n[0], n[1], n[0]+n[2], n[1]+n[3]), nil
+}
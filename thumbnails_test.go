@@ -0,0 +1,83 @@
+package hdrezka
+
+import (
+	"image"
+	"image/color"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseThumbnailsVTT(t *testing.T) {
+	t.Parallel()
+
+	const vtt = `WEBVTT
+
+00:00:00.000 --> 00:00:10.000
+sprite1.jpg#xywh=0,0,160,90
+
+00:00:10.000 --> 00:00:20.000
+sprite1.jpg#xywh=160,0,160,90
+
+01:00.000 --> 01:10.500
+https://cdn.example.com/sprite2.jpg
+`
+	base, _ := url.Parse("https://hdrezka.ag/thumbnails/123/thumbs.vtt")
+	cues, err := parseThumbnailsVTT(strings.NewReader(vtt), base)
+	if err != nil {
+		t.Fatalf("parseThumbnailsVTT() error = %v", err)
+	}
+	want := []ThumbnailCue{
+		{0, 10 * time.Second, "https://hdrezka.ag/thumbnails/123/sprite1.jpg", image.Rect(0, 0, 160, 90)},
+		{10 * time.Second, 20 * time.Second, "https://hdrezka.ag/thumbnails/123/sprite1.jpg", image.Rect(160, 0, 320, 90)},
+		{time.Minute, 70*time.Second + 500*time.Millisecond, "https://cdn.example.com/sprite2.jpg", image.Rectangle{}},
+	}
+	if len(cues) != len(want) {
+		t.Fatalf("parseThumbnailsVTT() = %+v, want %+v", cues, want)
+	}
+	for i := range want {
+		if cues[i] != want[i] {
+			t.Errorf("cue[%d] = %+v, want %+v", i, cues[i], want[i])
+		}
+	}
+}
+
+func TestContactSheet(t *testing.T) {
+	t.Parallel()
+
+	// The sprite holds two 10x10 frames: black on the left, white on the right.
+	sprite := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	for x := 0; x < 20; x++ {
+		for y := 0; y < 10; y++ {
+			if x < 10 {
+				sprite.Set(x, y, color.Black)
+			} else {
+				sprite.Set(x, y, color.White)
+			}
+		}
+	}
+	frames := []image.Image{
+		cropImage(sprite, image.Rect(0, 0, 10, 10)),
+		cropImage(sprite, image.Rect(10, 0, 20, 10)),
+		cropImage(sprite, image.Rect(10, 0, 20, 10)),
+	}
+
+	sheet := ContactSheet(frames, 2)
+	if got, want := sheet.Bounds(), image.Rect(0, 0, 20, 20); got != want {
+		t.Fatalf("ContactSheet bounds = %v, want %v", got, want)
+	}
+	white := color.RGBAModel.Convert(color.White)
+	black := color.RGBAModel.Convert(color.Black)
+	checks := map[image.Point]color.Color{
+		{5, 5}:   black, // first frame is the black half
+		{15, 5}:  white, // second frame is the white half
+		{5, 15}:  white, // third frame wraps to the next row
+		{15, 15}: black, // unused cell keeps the background
+	}
+	for pt, want := range checks {
+		if got := color.RGBAModel.Convert(sheet.At(pt.X, pt.Y)); got != want {
+			t.Errorf("sheet.At(%v) = %v, want %v", pt, got, want)
+		}
+	}
+}
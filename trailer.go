@@ -0,0 +1,75 @@
+package hdrezka
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Trailer is a struct for trailer info
+type Trailer struct {
+	Title string `json:"title"`
+	// EmbedURL is the player page of the trailer (usually YouTube).
+	EmbedURL string `json:"embed_url,omitempty"`
+	// URL is a direct media file, set only when the site serves one.
+	URL string `json:"url,omitempty"`
+}
+
+// GetTrailer returns the trailer shown by the "Смотреть трейлер" button.
+func (video *Video) GetTrailer() (*Trailer, error) {
+	var data struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Success bool   `json:"success"`
+		Title   string `json:"title"`
+	}
+	err := video.r.postAJAX("/engine/ajax/gettrailervideo.php", url.Values{"id": {video.ID}}, &data)
+	if err != nil {
+		return nil, err
+	}
+	if !data.Success {
+		return nil, errors.New("failed to get trailer: " + data.Message)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(data.Code))
+	if err != nil {
+		return nil, err
+	}
+	trailer := &Trailer{
+		Title:    strings.TrimSpace(data.Title),
+		EmbedURL: absoluteURL(doc.Find("iframe").AttrOr("src", "")),
+		URL:      absoluteURL(doc.Find("video[src]").AttrOr("src", doc.Find("video > source").AttrOr("src", ""))),
+	}
+	if trailer.URL == "" && isMediaFile(trailer.EmbedURL) {
+		trailer.URL = trailer.EmbedURL
+	}
+	if trailer.EmbedURL == "" && trailer.URL == "" {
+		return nil, errors.New("trailer not found")
+	}
+	return trailer, nil
+}
+
+// absoluteURL turns protocol-relative "//host/path" links into https URLs.
+func absoluteURL(link string) string {
+	if strings.HasPrefix(link, "//") {
+		return "https:" + link
+	}
+	return link
+}
+
+// isMediaFile reports whether link points at a file that can be downloaded
+// directly rather than a player page.
+func isMediaFile(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".mp4", ".m3u8", ".webm":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package hdrezka
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestVideoGetTrailer(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/engine/ajax/gettrailervideo.php" || req.FormValue("id") == "" {
+			http.NotFound(w, req)
+			return
+		}
+		switch req.FormValue("id") {
+		case "1":
+			fmt.Fprint(w, `{"success":true,"message":"","code":"<iframe src=\"//www.youtube.com/embed/abc?autoplay=1\"></iframe>","title":"Трейлер"}`)
+		case "2":
+			fmt.Fprint(w, `{"success":true,"message":"","code":"<video><source src=\"https://cdn.example.com/trailer.mp4\"></video>","title":"Трейлер"}`)
+		default:
+			fmt.Fprint(w, `{"success":false,"message":"Трейлер не найден"}`)
+		}
+	}))
+	defer srv.Close()
+
+	r := New()
+	r.URL, _ = url.Parse(srv.URL)
+
+	trailer, err := (&Video{r: r, ID: "1"}).GetTrailer()
+	if err != nil {
+		t.Fatalf("GetTrailer() error = %v", err)
+	}
+	if trailer.EmbedURL != "https://www.youtube.com/embed/abc?autoplay=1" || trailer.URL != "" || trailer.Title != "Трейлер" {
+		t.Errorf("GetTrailer() = %+v, want a YouTube embed only", trailer)
+	}
+
+	trailer, err = (&Video{r: r, ID: "2"}).GetTrailer()
+	if err != nil {
+		t.Fatalf("GetTrailer() error = %v", err)
+	}
+	if trailer.URL != "https://cdn.example.com/trailer.mp4" {
+		t.Errorf("GetTrailer().URL = %q, want the direct file", trailer.URL)
+	}
+
+	if _, err := (&Video{r: r, ID: "3"}).GetTrailer(); err == nil {
+		t.Error("GetTrailer() without trailer returned no error")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/net/proxy"
@@ -31,6 +32,59 @@ func (r *HDRezka) WithResolver(addr string) *HDRezka {
 	return r
 }
 
+// WithRateLimit spaces requests to the site at least interval apart, shared
+// by all goroutines using the client, so bulk calls such as GetVideos do not
+// trip the site's flood protection. Stream and CDN downloads are not
+// limited. Zero disables the limit. The change takes effect on the next Init.
+func (r *HDRezka) WithRateLimit(interval time.Duration) *HDRezka {
+	r.rateInterval = interval
+	r.initialized = false
+	return r
+}
+
+// isSiteRequest reports whether req goes to the active site host.
+func (r *HDRezka) isSiteRequest(req *http.Request) bool {
+	return r.URL == nil || req.URL.Host == r.URL.Host
+}
+
+// rateLimitTransport delays requests matched by limit so that they start at
+// least interval apart.
+type rateLimitTransport struct {
+	next     http.RoundTripper
+	interval time.Duration
+	limit    func(*http.Request) bool
+
+	mu   sync.Mutex
+	slot time.Time // earliest start of the next limited request
+}
+
+func newRateLimitTransport(next http.RoundTripper, interval time.Duration, limit func(*http.Request) bool) *rateLimitTransport {
+	return &rateLimitTransport{next: next, interval: interval, limit: limit}
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.limit == nil || t.limit(req) {
+		t.mu.Lock()
+		start := t.slot
+		if now := time.Now(); start.Before(now) {
+			start = now
+		}
+		t.slot = start.Add(t.interval)
+		t.mu.Unlock()
+
+		if wait := time.Until(start); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-req.Context().Done():
+				timer.Stop()
+				return nil, req.Context().Err()
+			}
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
 func buildTransport(proxyAddr, resolverAddr string) (http.RoundTripper, error) {
 	dialer := &net.Dialer{
 		Timeout:   30 * time.Second,
@@ -0,0 +1,44 @@
+package hdrezka
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimitTransport(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer srv.Close()
+
+	const interval = 30 * time.Millisecond
+	limited := func(req *http.Request) bool { return !strings.HasPrefix(req.URL.Path, "/cdn/") }
+	client := &http.Client{Transport: newRateLimitTransport(http.DefaultTransport, interval, limited)}
+
+	get := func(path string) {
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	start := time.Now()
+	for range 3 {
+		get("/page")
+	}
+	if elapsed := time.Since(start); elapsed < 2*interval {
+		t.Errorf("3 limited requests took %v, want at least %v", elapsed, 2*interval)
+	}
+
+	start = time.Now()
+	for range 3 {
+		get("/cdn/segment.ts")
+	}
+	if elapsed := time.Since(start); elapsed >= interval {
+		t.Errorf("3 unlimited requests took %v, want no delay", elapsed)
+	}
+}
@@ -1,69 +1,198 @@
 package hdrezka
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
+	"iter"
 	"net/http"
+	"net/url"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/PuerkitoBio/goquery"
 )
 
 var (
-	reQualityTag = regexp.MustCompile(`\[([^\]]+)\]`)
-	reTranslate  = regexp.MustCompile(`initCDN(Series|Movies)Events\(\d+,\s(\d+),.+?(\{.*?\})\);`)
+	reAge           = regexp.MustCompile(`(\d+)\+`)
+	reDate          = regexp.MustCompile(`(\d{1,2})\s+(\p{L}+)\s+(\d{4})`)
+	reHours         = regexp.MustCompile(`(\d+)\s*ч`)
+	reIMDbID        = regexp.MustCompile(`tt\d{5,}`)
+	reKinopoiskID   = regexp.MustCompile(`kinopoisk\.ru/(?:film|series)/(\d+)`)
+	reMinutes       = regexp.MustCompile(`(\d+)\s*мин`)
+	reQualityTag    = regexp.MustCompile(`\[([^\]]+)\]`)
+	reStatusEpisode = regexp.MustCompile(`(\d+)\s*сери`)
+	reSeriesState   = regexp.MustCompile(`initCDNSeriesEvents\(\d+,\s*\d+,\s*(\d+),\s*(\d+)`)
+	reStatusSeason  = regexp.MustCompile(`(\d+)\s*сезон`)
+	reVideoID       = regexp.MustCompile(`/(\d+)-[^/]*\.html`)
+	reYear          = regexp.MustCompile(`\d{4}`)
+	reTranslate     = regexp.MustCompile(`initCDN(Series|Movies)Events\(\d+,\s(\d+),.+?(\{.*?\})\);`)
+)
+
+// russianMonths maps Russian month names, in the genitive case used by
+// release dates ("12 апреля 2023 года") as well as the nominative, to months.
+var russianMonths = map[string]time.Month{
+	"января": time.January, "январь": time.January,
+	"февраля": time.February, "февраль": time.February,
+	"марта": time.March, "март": time.March,
+	"апреля": time.April, "апрель": time.April,
+	"мая": time.May, "май": time.May,
+	"июня": time.June, "июнь": time.June,
+	"июля": time.July, "июль": time.July,
+	"августа": time.August, "август": time.August,
+	"сентября": time.September, "сентябрь": time.September,
+	"октября": time.October, "октябрь": time.October,
+	"ноября": time.November, "ноябрь": time.November,
+	"декабря": time.December, "декабрь": time.December,
+}
 
-	// knownSalts are the obfuscation patterns HDrezka inserts after each
-	// "//_//" marker in encoded stream URLs. They vary in length, so the
-	// decoder strips them by exact match rather than a fixed offset.
-	knownSalts = []string{
-		"IyMjI14hISMjIUBA", "QEBAQEAhIyMhXl5e",
-		"JCQhIUAkJEBeIUAjJCRA", "JCQjISFAIyFAIyM=", "Xl5eIUAjIyEhIyM=",
+// trashSymbols are the characters the player builds its salt strings from.
+const trashSymbols = "@#!^$"
+
+// maxDecodeAttempts bounds the search over salt lengths in decodeURL.
+const maxDecodeAttempts = 256
+
+// saltGroups holds the base64 encoding of every 2- and 3-character
+// combination of trashSymbols, generated the way the player does. A salt
+// inserted after a "//_//" marker is a run of these 4-character groups.
+var saltGroups = func() map[string]bool {
+	groups := make(map[string]bool)
+	var combine func(prefix string, n int)
+	combine = func(prefix string, n int) {
+		if n == 0 {
+			groups[base64.StdEncoding.EncodeToString([]byte(prefix))] = true
+			return
+		}
+		for _, c := range trashSymbols {
+			combine(prefix+string(c), n-1)
+		}
 	}
-)
+	combine("", 2)
+	combine("", 3)
+	return groups
+}()
+
+// DecodeError is returned when an obfuscated stream URL cannot be decoded
+// into a "[quality]url" list, typically because the site changed its salts.
+type DecodeError struct {
+	// Input is the encoded string as received from the site.
+	Input  string
+	Reason string
+}
 
-// saltFallbackLen is the number of characters the official app strips after a
-// "//_//" marker when it does not recognize the trailing salt (FilmModel.decodeUrl).
-const saltFallbackLen = 16
+func (e *DecodeError) Error() string {
+	return "cannot decode stream URL: " + e.Reason
+}
 
 func decodeURL(url string) (string, error) {
+	// Videos without a stream (announcements, blocked titles) have none
+	if strings.TrimSpace(url) == "" {
+		return "", nil
+	}
 	// New format: URL is already in plain text (no base64 encoding)
 	if strings.HasPrefix(url, "[") || strings.HasPrefix(url, "http") {
 		return url, nil
 	}
 
-	// Old format: base64 encoded with obfuscation patterns. For each "//_//"
-	// marker, strip a known salt of its exact length; if none matches, fall
-	// back to the app's behaviour of dropping the marker plus a fixed offset.
-	url = strings.TrimPrefix(url, "#h")
-	for i := 0; i < 60 && strings.Contains(url, "//_//"); i++ {
-		idx := strings.Index(url, "//_//")
-		after := url[idx+5:]
-		matched := false
-		for _, s := range knownSalts {
-			if strings.HasPrefix(after, s) {
-				url = url[:idx] + after[len(s):]
-				matched = true
-				break
+	// Old format: base64 with a salt after each "//_//" marker. Strip the
+	// longest run of salt groups first and back off one group at a time
+	// until the result decodes to a stream list whose every entry is a URL,
+	// since a payload group can look like salt. A list with any other entry
+	// means a salt was left behind, so it is an error, not a result.
+	parts := strings.Split(strings.TrimPrefix(url, "#h"), "//_//")
+	runs := make([]int, len(parts)-1)
+	for i, part := range parts[1:] {
+		for len(part) >= (runs[i]+1)*4 && saltGroups[part[runs[i]*4:(runs[i]+1)*4]] {
+			runs[i]++
+		}
+	}
+
+	strip := make([]int, len(runs))
+	attempts := 0
+	var search func(i int) (string, bool)
+	search = func(i int) (string, bool) {
+		if i == len(runs) {
+			attempts++
+			var b strings.Builder
+			b.WriteString(parts[0])
+			for j, part := range parts[1:] {
+				b.WriteString(part[strip[j]*4:])
+			}
+			decoded, err := base64.StdEncoding.DecodeString(b.String())
+			if err != nil {
+				return "", false
 			}
+			return string(decoded), isStreamList(string(decoded))
 		}
-		if !matched {
-			// Unknown salt: app-style fallback (drop "//_//" + fixed offset).
-			end := idx + 5 + saltFallbackLen
-			if end > len(url) {
-				end = len(url)
+		for n := runs[i]; n >= 0 && attempts < maxDecodeAttempts; n-- {
+			strip[i] = n
+			if decoded, ok := search(i + 1); ok {
+				return decoded, true
 			}
-			url = url[:idx] + url[end:]
 		}
+		return "", false
+	}
+	if decoded, ok := search(0); ok {
+		return decoded, nil
+	}
+
+	for i, n := range runs {
+		if n == 0 {
+			return "", &DecodeError{Input: url, Reason: fmt.Sprintf("unknown salt after marker %d", i+1)}
+		}
+	}
+	return "", &DecodeError{Input: url, Reason: fmt.Sprintf("no valid stream list after %d attempts", attempts)}
+}
+
+// isStreamList reports whether s is a decoded "[quality]url,..." list whose
+// every entry is a URL, which tells a cleanly stripped salt from one that
+// left garbage behind.
+func isStreamList(s string) bool {
+	if !utf8.ValidString(s) || !strings.HasPrefix(s, "[") {
+		return false
+	}
+	locs := reQualityTag.FindAllStringIndex(s, -1)
+	if len(locs) == 0 {
+		return false
+	}
+	for i, loc := range locs {
+		end := len(s)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		if !strings.HasPrefix(strings.TrimSpace(s[loc[1]:end]), "http") {
+			return false
+		}
+	}
+	return true
+}
+
+// videoIDFromURL extracts the numeric post ID from a video page URL such as
+// "/films/drama/12345-title-2023.html".
+func videoIDFromURL(videoURL string) string {
+	if m := reVideoID.FindStringSubmatch(videoURL); m != nil {
+		return m[1]
 	}
-	decoded, err := base64.StdEncoding.DecodeString(url)
+	return ""
+}
+
+// genreFromURL returns the genre encoded in the first path segment of a
+// video URL, or All when it is not a known genre.
+func genreFromURL(videoURL string) Genre {
+	u, err := url.Parse(videoURL)
 	if err != nil {
-		// Fallback: if base64 decoding fails, return as-is
-		return url, nil
+		return All
+	}
+	segment, _, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if genre := Genre(segment); slices.Contains(genres, genre) {
+		return genre
 	}
-	return string(decoded), nil
+	return All
 }
 
 // boolTo10 maps a boolean to the "1"/"0" string flags HDrezka AJAX endpoints expect.
@@ -86,7 +215,27 @@ func getCategory(selector string, doc *goquery.Document) map[string]string {
 }
 
 func (r *HDRezka) getDoc(uri string) (*goquery.Document, error) {
-	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	return r.getDocContext(context.Background(), uri)
+}
+
+func (r *HDRezka) getDocContext(ctx context.Context, uri string) (*goquery.Document, error) {
+	body, err := r.getBodyContext(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return goquery.NewDocumentFromReader(body)
+}
+
+// getBody issues a browser-like GET and returns the body of a 200 reply.
+// The caller must close it.
+func (r *HDRezka) getBody(uri string) (io.ReadCloser, error) {
+	return r.getBodyContext(context.Background(), uri)
+}
+
+func (r *HDRezka) getBodyContext(ctx context.Context, uri string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -96,43 +245,126 @@ func (r *HDRezka) getDoc(uri string) (*goquery.Document, error) {
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
+		resp.Body.Close()
 		return nil, fmt.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
 	}
 
-	return goquery.NewDocumentFromReader(resp.Body)
+	return resp.Body, nil
 }
 
 func (r *HDRezka) getItems(url string, maxItems int) ([]*CoverItem, error) {
 	items := make([]*CoverItem, 0)
-	for {
-		doc, err := r.getDoc(url)
+	if maxItems <= 0 {
+		return items, nil
+	}
+	for item, err := range r.iterItems(url) {
 		if err != nil {
 			return nil, err
 		}
+		items = append(items, item)
+		if len(items) >= maxItems {
+			break
+		}
+	}
+	return items, nil
+}
 
-		doc.Find("div.b-content__inline_items > div.b-content__inline_item").Each(func(i int, s *goquery.Selection) {
-			if len(items) == maxItems {
+// iterItems walks a paginated listing starting at url. A page is only
+// requested once the consumer has drained the previous one, so breaking out
+// of the loop stops any further network I/O.
+func (r *HDRezka) iterItems(url string) iter.Seq2[*CoverItem, error] {
+	return func(yield func(*CoverItem, error) bool) {
+		for page := 1; url != ""; page++ {
+			doc, err := r.getDoc(url)
+			if err != nil {
+				yield(nil, err)
 				return
 			}
-			link := s.Find("div.b-content__inline_item-link > a")
-			items = append(items, &CoverItem{
-				Cover:       s.Find("div.b-content__inline_item-cover > a > img").AttrOr("src", ""),
-				Description: strings.ReplaceAll(s.Find("div.b-content__inline_item-link > div").Text(), " - ...", ""),
-				Info:        s.Find("span.info").Text(),
-				Title:       link.Text(),
-				URL:         link.AttrOr("href", ""),
-			})
-		})
-
-		url = doc.Find(".b-navigation__next").Parent().AttrOr("href", "")
-		if len(items) >= maxItems || url == "" {
-			break
+
+			totalPages := getTotalPages(doc)
+			for _, s := range doc.Find("div.b-content__inline_items > div.b-content__inline_item").EachIter() {
+				item := newCoverItem(s)
+				item.Page = page
+				item.TotalPages = totalPages
+				if !yield(item, nil) {
+					return
+				}
+			}
+
+			url = doc.Find(".b-navigation__next").Parent().AttrOr("href", "")
 		}
 	}
-	return items, nil
+}
+
+// getTotalPages returns the highest page number linked from the listing
+// navigation block. A listing without navigation is a single page.
+func getTotalPages(doc *goquery.Document) int {
+	total := 1
+	doc.Find(".b-navigation > a, .b-navigation > span").Each(func(i int, s *goquery.Selection) {
+		if n, err := strconv.Atoi(strings.TrimSpace(s.Text())); err == nil && n > total {
+			total = n
+		}
+	})
+	return total
+}
+
+// parseReleaseDate parses a Russian date such as "12 апреля 2023 года".
+// It returns the zero time when the day or month is missing.
+func parseReleaseDate(str string) time.Time {
+	m := reDate.FindStringSubmatch(strings.ToLower(str))
+	if m == nil {
+		return time.Time{}
+	}
+	month, found := russianMonths[m[2]]
+	if !found {
+		return time.Time{}
+	}
+	return time.Date(parseInt(m[3]), month, parseInt(m[1]), 0, 0, 0, 0, time.UTC)
+}
+
+// parseRuntime parses durations such as "98 мин." or "1 ч. 38 мин.".
+func parseRuntime(str string) time.Duration {
+	var d time.Duration
+	if m := reHours.FindStringSubmatch(str); m != nil {
+		d += time.Duration(parseInt(m[1])) * time.Hour
+	}
+	if m := reMinutes.FindStringSubmatch(str); m != nil {
+		d += time.Duration(parseInt(m[1])) * time.Minute
+	}
+	return d
+}
+
+// parseMinAge returns the age rating from strings such as "16+ только для
+// взрослых", or zero when none is given.
+func parseMinAge(str string) int {
+	if m := reAge.FindStringSubmatch(str); m != nil {
+		return parseInt(m[1])
+	}
+	return 0
+}
+
+// decodeHelpLink unwraps the site's external-link redirector
+// ("/help/<base64 of the escaped target>/") and returns the target URL.
+// Hrefs that do not go through the redirector are returned unchanged.
+func decodeHelpLink(href string) string {
+	_, token, found := strings.Cut(href, "/help/")
+	if !found {
+		return href
+	}
+	token = strings.Trim(token, "/")
+	if unescaped, err := url.PathUnescape(token); err == nil {
+		token = unescaped
+	}
+	decoded, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return href
+	}
+	if target, err := url.QueryUnescape(string(decoded)); err == nil {
+		return target
+	}
+	return string(decoded)
 }
 
 func parseFloat(str string) float64 {
@@ -152,6 +384,26 @@ func parseInt(str string) int {
 	return i
 }
 
+// hlsSuffix turns the path of an MP4 file into the path of its HLS manifest.
+const hlsSuffix = ":hls:manifest.m3u8"
+
+// urlPath returns the path of a stream URL, so suffix checks ignore the
+// query string.
+func urlPath(u string) string {
+	if parsed, err := url.Parse(u); err == nil {
+		return parsed.Path
+	}
+	return u
+}
+
+// cutQuery splits a stream URL before its query string or fragment.
+func cutQuery(u string) (base, query string) {
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		return u[:i], u[i:]
+	}
+	return u, ""
+}
+
 func parseStreamFormats(str string) map[string]VideoFormat {
 	formats := make(map[string]VideoFormat)
 
@@ -170,51 +422,71 @@ func parseStreamFormats(str string) map[string]VideoFormat {
 		// Split alternatives separated by " or "
 		urls := strings.Split(urlStr, " or ")
 
-		var hls, mp4 string
+		var hls, mp4 []string
 
-		// Check if any URL has :hls:manifest.m3u8 suffix (new format)
+		// Check if any URL path has the HLS suffix (new format)
 		hasHLSSuffix := false
 		for _, u := range urls {
-			if strings.HasSuffix(strings.TrimSpace(u), ":hls:manifest.m3u8") {
+			if strings.HasSuffix(urlPath(strings.TrimSpace(u)), hlsSuffix) {
 				hasHLSSuffix = true
 				break
 			}
 		}
 
 		if hasHLSSuffix {
-			// New format: classify by suffix
+			// New format: classify by suffix, keeping the site order and
+			// dropping duplicates and broken entries
 			for _, u := range urls {
 				u = strings.TrimSpace(u)
-				if u == "" {
-					continue
-				}
-				if strings.HasSuffix(u, ":hls:manifest.m3u8") {
-					if hls == "" {
-						hls = u
+				switch p := urlPath(u); {
+				case strings.HasSuffix(p, hlsSuffix):
+					if !slices.Contains(hls, u) {
+						hls = append(hls, u)
 					}
-				} else if mp4 == "" {
-					mp4 = u
+				case strings.HasSuffix(p, ".mp4"):
+					if !slices.Contains(mp4, u) {
+						mp4 = append(mp4, u)
+					}
+				}
+			}
+			// Every MP4 node also serves the HLS manifest of the file
+			for _, u := range mp4 {
+				base, query := cutQuery(u)
+				if manifest := base + hlsSuffix + query; !slices.Contains(hls, manifest) {
+					hls = append(hls, manifest)
 				}
 			}
-			if hls != "" && mp4 == "" {
-				mp4 = strings.TrimSuffix(hls, ":hls:manifest.m3u8")
+			if len(mp4) == 0 {
+				for _, u := range hls {
+					base, query := cutQuery(u)
+					mp4 = append(mp4, strings.TrimSuffix(base, hlsSuffix)+query)
+				}
 			}
 		} else {
-			// Old format: first URL is HLS, second is MP4
-			if len(urls) >= 1 {
-				hls = strings.TrimSpace(urls[0])
+			// Old format: first URL is HLS, the last one is the preferred
+			// MP4 and anything in between is a backup MP4 node
+			for i := range urls {
+				urls[i] = strings.TrimSpace(urls[i])
 			}
+			hls = urls[:1]
 			if len(urls) >= 2 {
-				mp4 = strings.TrimSpace(urls[len(urls)-1])
+				mp4 = append([]string{urls[len(urls)-1]}, urls[1:len(urls)-1]...)
 			} else {
 				mp4 = hls
 			}
 		}
 
-		formats[quality] = VideoFormat{
-			HLS: hls,
-			MP4: mp4,
+		format := VideoFormat{
+			HLSURLs: hls,
+			MP4URLs: mp4,
+		}
+		if len(hls) > 0 {
+			format.HLS = hls[0]
+		}
+		if len(mp4) > 0 {
+			format.MP4 = mp4[0]
 		}
+		formats[quality] = format
 	}
 
 	return formats
@@ -222,21 +494,8 @@ func parseStreamFormats(str string) map[string]VideoFormat {
 
 func parseSubtitles(subs string) map[string]string {
 	subtitles := make(map[string]string)
-	for _, entry := range strings.Split(subs, ",") {
-		endBracket := strings.Index(entry, "]")
-		if endBracket == -1 {
-			continue
-		}
-		key := entry[1:endBracket]
-		value := entry[endBracket+1:]
-		key = strings.TrimSpace(key)
-		value = strings.TrimSpace(value)
-		// A subtitle entry may carry alternatives separated by " or "; the
-		// site/app uses the last one (consistent with parseStreamFormats).
-		if parts := strings.Split(value, " or "); len(parts) > 1 {
-			value = strings.TrimSpace(parts[len(parts)-1])
-		}
-		subtitles[key] = value
+	for _, track := range parseSubtitleTracks(subs, nil, nil) {
+		subtitles[track.Label] = track.URL
 	}
 	return subtitles
 }
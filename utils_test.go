@@ -2,29 +2,38 @@ package hdrezka
 
 import (
 	"encoding/base64"
+	"errors"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 // encodedSample is a real obfuscated stream URL captured from HDrezka
-// (2025-04-04). It exercises every known salt, including the 20-char
+// (2025-04-04). Its salts vary in length, including the 20-char
 // "//_//JCQhIUAkJEBeIUAjJCRA" that a fixed 16-char strip would corrupt.
 const encodedSample = "#hWzM2MHBdaHR0cHM6Ly9mZW1lcmV0ZXMub3JnLzI2ODA2NGQ0NDlmNTdmODUwNDU3YzEzY2Q3OGI4N2VkOjIwMjUwNDA0MTY6TDBSV1UydDZWSGxxYjJWWVNuSnViVlV4YzFwdFJFVnpTREo0T//_//IyMjI14hISMjIUBATAxS1NscGxVekJRV1dRd2NtdGtkbWRsVTJkWUwwOXhXbnBOSzNSeVR6SmtMM1pWWW04d2RHRXJjWE5hUmxCUVZsSnBPVXMxYWtOWmVtbE5XRGhWYUdOclN6bHhOMVl3YVdOckwydENRbEU5LzEvMS8yLzQvMy8yLzUvb2xxMnEubXA0OmhsczptYW5pZmVzdC5tM3U4IG9yIGh0dHBzOi8vc3RyZWFtLnZvaWRib29zdC5jYy8yNjgwNjRkNDQ5ZjU3Zjg1MDQ1N2MxM2NkNzhiODdlZDoyMDI1MDQwNDE2OkwwUldVMnQ2VkhscWIyVllTbkp1YlZVeGMxcHRSRVZ6U0RKNE0wMUtTbHBsVXpCUVdXUXdjbXRrZG1kbFUyZFlMMDl4V25wTkszUnlUekprTDNaVlltOHdkR0VyY1hOYVJsQlFWbEpwT1VzMWFrTlplbWxOV0RoVmFHTnJTemx4TjFZd2FXTnJMMnRDUWxFOS8xLzEvMi80LzMvMi81L29scTJxLm1wNCBvciBodHRwczovL2ZlbWVyZXRlcy5vcmcvMjY4MDY0ZDQ0OWY1N2Y4NTA0NTdjMTNjZDc4Yjg3ZWQ6MjAyNTA0MDQxNjpMMFJXVTJ0NlZIbHFiMlZZU25KdWJWVXhjMXB0UkVWelNESjRNMDFLU2xwbFV6QlFXV1F3Y210a2RtZGxVMmRZTDA5eFducE5LM1J5VHpKa0wzWlZZbTh3ZEdFcmNYTmFSbEJRVmxKcE9VczFha05aZW1sTldEaFZhR05yU3pseE4xWXdhV05yTDJ0Q1FsRTkvMS8xLzIvNC8zLzIvNS9vbHEycS5tcDQgb3IgaHR0cHM6Ly9zdHJlYW0udm9pZGJvb3N0LmNjLzI2ODA2NGQ0NDlmNTdmODUwNDU3YzEzY2Q3OGI4N2VkOjIwMjUwNDA0MTY6TDBSV1UydDZWSGxxYjJWWVNuSnViVlV4YzFwdFJ//_//QEBAQEAhIyMhXl5eFVnpTREo0TTAxS1NscGxVekJRV1dRd2NtdGtkbWRsVTJkWUwwOXhXbnBOSzNSeVR6SmtMM1pWWW04d2RHRXJjWE5hUmxCUVZsSnBPVXMxYWtOWmVtbE5XRGhWYUdOclN6bHhOMVl3YVdOckwydENRbEU5LzEvMS8yLzQvMy8yLzUvb2xxMnEubXA0LFs0ODBwXWh0dHBzOi8vZmVtZXJldGVzLm9yZy8wMjczMTJmNjAzMzA2ZWZhMGI2MzdkNzRkN2EyZDFlYzoyMDI1MDQwNDE2OkwwUldVMnQ2VkhscWIyVllTbkp1YlZVeGMxcHRSRVZ6U0RKNE0wMUtTbHBsVXpCUVdXUXdjbXRrZG1kbFUyZFlMMDl4V25wTkszUnlUekprTDNaVlltOHdkR0VyY1hOYVJsQlFWbEpwT1VzMWFrTlplbWxOV0RoVmFHTnJTemx4TjFZd2FXTnJMMnRDUWxFOS8xLzEvMi80LzMvMi81LzlpbThpLm1wNDpobHM6bWFuaWZlc3QubTN1OCBvciBodHRwczovL3N0cmVhbS52b2lkYm9vc3QuY2MvMDI3MzEyZjYwMzMwNmVmYTBiNjM3ZDc0ZDdhMmQxZWM6MjAyNTA0MDQxNjpMMFJXVTJ0NlZIbHFiMlZZU25KdWJWVXhjMXB0UkVWelNESjRNMDFLU2xwbFV6QlFXV1F3Y210a2RtZGxVMmRZTDA5eFducE5LM1J5VHpKa0wzWlZZbTh3ZEdFcmNYTmFSbEJRVmxKcE9VczFha05aZW1sTldEaFZhR05yU3pseE4xWXdhV05yTDJ0Q1FsRTkvMS8xLzIvNC8zLzIvNS85aW04aS5tcDQgb3IgaHR0cHM6Ly9mZW1lcmV0ZXMub3JnLzAyNzMxMmY2MDMzMDZlZmEwYjYzN2Q3NGQ3YTJkMWVjOj//_//Xl5eIUAjIyEhIyM=N0Lm0zdTggb3IgaHR0cHM6Ly9mZW1lcmV0ZXMub3JnLzAyNzMxMmY2MDMzMDZlZmEwYjYzN2Q3NGQ3YTJkMWVjOj//_//JCQjISFAIyFAIyM=IwMjUwNDA0MTY6TDBSV1UydDZWSGxxYjJWWVNuSnViVlV4YzFwdFJFVnpTREo0TTAxS1NscGxVekJRV1dRd2NtdGtkbWRsVTJkWUwwOXhXbnBOSzNSeVR6SmtMM1pWWW04d2RHRXJjWE5hUmxCUVZsSnBPVXMxYWtOWmVtbE5XRGhWYUdOclN6bHhOMVl3YVdOckwydENRbEU5LzEvMS8yLzQvMy8yLzUvOWltOGkubXA0IG9yIGh0dHBzOi8vc3RyZWFtLnZvaWRib29zdC5jYy8wMjczMTJmNjAzMzA2ZWZhMGI2MzdkNzRkN2EyZDFlYzoyMDI1MDQwNDE2OkwwUldVMnQ2VkhscWIyVllTbkp1YlZVeGMxcHRSRVZ6U0RKNE0wMUtTbHBsVXpCUVdXUXdjbXRrZG1kbFUyZFlMMDl4V25wTkszUnlUekprTDNaVlltOHdkR0VyY1hOYVJsQlFWbEpwT1VzMWFrTlplbWxOV0RoVmFHTnJTemx4TjFZd2FXTnJMMnRDUWxFOS8xLzEvMi80LzMvMi81LzlpbThpLm1wNCxbNzIwcF1odHRwczovL2ZlbWVyZXRlcy5vcmcvZmUyMmE0NmI5ZDM4ZTI1MmNjNWY1ZWIyNGQ4MTQ3YzE6MjAyNTA0MDQxNjpMMFJXVTJ0NlZIbHFiMlZZU25KdWJWVXhjMXB0UkVWelNESjRNMDFLU2xwbFV6QlFXV1F3Y210a2RtZGxVMmRZTDA5eFducE5LM1J5VHpKa0wzWlZZbTh3ZEdFcmNYTmFSbEJRVmxKcE9VczFha05aZW1sTldEaFZhR05yU3pseE4xWXdhV05yTDJ0Q1FsRTkvMS8xLzIvNC8zLzIvNS9jZmlzYy5tcDQ6aGxzOm1hbmlmZXN0Lm0zdTggb3IgaHR0cHM6Ly9zdHJlYW0udm9pZGJvb3N0LmNjL2ZlMjJhNDZiOWQzOGUyNTJjYzVmNWViMjRkODE0N2MxOjIwMjUwNDA0MTY6TDBSV1UydDZWSGxxYjJWWVNuSnViVlV4YzFwdFJFVnpTREo0TTAxS1NscGxVekJRV1dRd2NtdGtkbWRsVTJkWUwwOXhXbnBOSzNSeVR6SmtMM1pWWW04d2RHRXJjWE5hUmxCUVZsSnBPVXMxYWtOWmVtbE5XRGhWYUdOclN6bHhOMVl3YVdOckwydENRbEU5LzEvMS8yLzQvMy8yLzUvY2Zpc2MubXA0OmhsczptYW5pZmVzdC5tM3U4IG9yIGh0dHBzOi8vZmVtZXJldGVzLm9yZy9mZTIyYTQ2YjlkMzhlMjUyY2M1ZjVlYjI0ZDgxNDdjMToyMDI1MDQwNDE2OkwwUldVMnQ2VkhscWIyVllTbkp1YlZVeGMxcHRSRVZ6U0RKNE0wMUtTbHBsVXpCUVdXUXdjbXRrZG1kbFUyZFlMMDl4V25wTkszUnlUekprTDNaVlltOHdkR0VyY1hOYVJsQlFWbEpwT1VzMWFrTlplbWxOV0RoVmFHTnJTemx4TjFZd2FXTnJMMnRDUWxFOS8xLzEvMi80LzMvMi81L2NmaXNjLm1wNCBvciBodHRwczovL3N0cmVhbS52b2lkYm9vc3QuY2MvZmUyMmE0NmI5ZDM4ZTI1MmNjNWY1ZWIyNGQ4MTQ3YzE6MjAyNTA0MDQxNjpMMFJXVTJ0NlZIbHFiMlZZU25KdWJWVXhjMXB0UkVWelNESjRNMDFLU2xwbFV6QlFXV1F3Y210a2RtZGxVMmRZTDA5eFducE5LM1J5VHpKa0wzWlZZbTh3ZEdFcmNYTmFSbEJRVmxKcE9VczFha05aZW1sTldEaFZhR05yU3pseE4xWXdhV05yTDJ0Q1FsRTkvMS8xLzIvNC8zLzIvNS9jZmlzYy5tcDQsWzEwODBwXWh0dHBzOi8vZmVtZXJldGVzLm9yZy9jOTQ1ZjNhMmVmOWVlNTZhOGNlMjNmMzQzOWU2NGI4NzoyMDI1MDQwNDE2OkwwUldVMnQ2VkhscWIyVllTbkp1YlZVeGMxcHRSRVZ6U0RKNE0wMUtTbHBsVXpCUVdXUXdjbXRrZG1kbFUyZFlMMDl4V25wTkszUnlUekprTDNaVlltOHdkR0VyY1hOYVJsQlFWbEpwT1VzMWFrTlplbWxOV0RoVmFHTnJTemx4TjFZd2FXTnJMMnRDUWxFOS8xLzEvMi80LzMvMi81L3pwN2VjLm1wNDpobHM6bWFuaWZlc3QubTN1OCBvciBodHRwczovL3N0cmVhbS52b2lkYm9vc3QuY2MvYzk0NWYzYTJlZjllZTU2YThjZTIzZjM0MzllNjRiODc6MjAyNTA0MDQxNjpMMFJXVTJ0NlZIbHFiMlZZU25KdWJWVXhjMXB0UkVWelNESjRNMDFLU2xwbFV6QlFXV1F3Y210a2RtZGxVMmRZTDA5eFducE5LM1J5VHpKa0wzWlZZbTh3ZEdFcmNYTmFSbEJRVmxKcE9VczFha05aZW1sTldEaFZhR05yU3pseE4xWXdhV05yTDJ0Q1FsRTkvMS8xLzIvNC8zLzIvNS96cDdlYy5tcDQgb3IgaHR0cHM6Ly9mZW1lcmV0ZXMub3JnL2M5NDVmM2EyZWY5ZWU1NmE4Y2UyM2YzNDM5ZTY0Yjg3OjIwMjUwNDA0MTY6TDBSV1UydDZWSGxxYjJWWVNuSnViVlV4YzFwdFJFVnpTREo0TTAxS1NscGxVekJRV1dRd2NtdGtkbWRsVTJkWUwwOXhXbnBOSzNSeVR6SmtMM1pWWW04d2RHRXJjWE5hUmxCUVZsSnBPVXMxYWtOWmVtbE5XRGhWYUdOclN6bHhOMVl3YVdOckwydENRbEU5LzEvMS8yLzQvMy8yLzUvenA3ZWMubXA0LFsxMDgwcCBVbHRyYV1odHRwczovL2ZlbWVyZXRlcy5vcmcvYzk0NWYzYTJlZjllZTU2YThjZTIzZjM0MzllNjRiODc6MjAyNTA0MDQxNjpMMFJXVTJ0NlZIbHFiMlZZU25KdWJWVXhjMXB0UkVWelNESjRNMDFLU2xwbFV6QlFXV1F3Y210a2RtZGxVMmRZTDA5eFducE5LM1J5VHpKa0wzWlZZbTh3ZEdFcmNYTmFSbEJRVmxKcE9VczFha05aZW1sTldEaFZhR05yU3pseE4xWXdhV05yTDJ0Q1FsRTkvMS8xLzIvNC8zLzIvNS96cDdlYy5tcDQ6aGxzOm1hbmlmZXN0Lm0zdTggb3IgaHR0cHM6Ly9zdHJlYW0udm9pZGJvb3N0LmNjL2M5NDVmM2EyZWY5ZWU1NmE4Y2UyM2YzNDM5ZTY0Yjg3OjIwMjUwNDA0MTY6TDBSV1UydDZWSGxxYjJWWVNuSnViVlV4YzFwdFJFVnpTREo0TTAxS1NscGxVekJRV1dRd2NtdGtkbWRsVTJkWUwwOXhXbnBOSzNSeVR6SmtMM1pWWW04d2RHRXJjWE5hUmxCUVZsSnBPVXMxYWtOWmVtbE5XRGhWYUdOclN6bHhOMVl3YVdOckwydENRbEU5LzEvMS8yLzQvMy8yLzUvenA3ZWMubXA0IG9yIGh0dHBzOi8vc3RyZWFtLnZvaWRib29zdC5jYy9jOTQ1ZjNhMmVmOWVlNTZhOGNlMjNmMzQzOWU2NGI4NzoyMDI1MDQwNDE2OkwwUldVMnQ2VkhscWIyVllTbkp1YlZVeGMxcHRSRVZ6U0RKNE0wMUtTbHBsVXpCUVdXUXdjbXRrZG1kbFUyZFlMMDl4V25wTkszUnlUekprTDNaVlltOHdkR0VyY1hOYVJsQlFWbEpwT1VzMWFrTlplbWxOV0RoVmFHTnJTemx4TjFZd2FXTnJMMnRDUWxFOS8xLzEvMi80LzMvMi81L3pwN2VjLm1wNA=="
 
 func TestDecodeURL(t *testing.T) {
 	t.Parallel()
 
-	// Synthetic case: an unknown 16-char salt must be stripped via the
-	// app-style fallback ("//_//" + 16) so the remaining base64 decodes cleanly.
-	payload := "the-real-decoded-payload-value"
+	// Synthetic cases: salts generated like the player's, made of base64
+	// groups of 2- and 3-character trash symbol combinations.
+	payload := "[720p]https://cdn.example.com/a.mp4 or https://backup.example.com/a.mp4"
 	b64 := base64.StdEncoding.EncodeToString([]byte(payload))
-	unknownSalt := "ABCDEFGHIJKLMNOP" // 16 chars, not in knownSalts
-	syntheticEncoded := b64[:8] + "//_//" + unknownSalt + b64[8:]
+	salt := func(combos ...string) string {
+		var s string
+		for _, c := range combos {
+			s += base64.StdEncoding.EncodeToString([]byte(c))
+		}
+		return s
+	}
 
 	tests := []struct {
 		name        string
 		input       string
 		want        string   // exact match (skipped when empty)
+		wantErr     bool     // a *DecodeError is expected
 		contains    []string // substrings that must be present
 		notContains []string // substrings that must be absent
 	}{
@@ -39,10 +48,42 @@ func TestDecodeURL(t *testing.T) {
 			want:  "http://example.com/video.mp4",
 		},
 		{
-			name:  "unknown 16-char salt degrades to app fallback",
-			input: syntheticEncoded,
+			name:  "empty URL of a video without a stream",
+			input: "",
+			want:  "",
+		},
+		{
+			name:    "list with an entry that is not a URL is an error",
+			input:   base64.StdEncoding.EncodeToString([]byte("[360p]http://example.com/360.mp4,[720p]")),
+			wantErr: true,
+		},
+		{
+			name:  "generated salts of any length are stripped",
+			input: "#h" + b64[:8] + "//_//" + salt("!^$", "@#") + b64[8:30] + "//_//" + salt("$$", "^!@", "###", "@@") + b64[30:],
 			want:  payload,
 		},
+		{
+			name:  "salt made of a single 2-character group",
+			input: b64[:17] + "//_//" + salt("^^") + b64[17:],
+			want:  payload,
+		},
+		{
+			name:    "unknown salt is an error, not a corrupted URL",
+			input:   b64[:8] + "//_//" + "ABCDEFGHIJKLMNOP" + b64[8:],
+			wantErr: true,
+		},
+		{
+			// "QUJD" is no salt group; stripping only "$$" leaves "ABC"
+			// before the URL
+			name:    "rotated salt is an error, not a corrupted URL",
+			input:   b64[:8] + "//_//" + salt("$$") + "QUJD" + b64[8:],
+			wantErr: true,
+		},
+		{
+			name:    "payload that is not a stream list is an error",
+			input:   base64.StdEncoding.EncodeToString([]byte("the-real-decoded-payload-value")),
+			wantErr: true,
+		},
 		{
 			name:  "real obfuscated sample decodes to plain stream URL",
 			input: encodedSample,
@@ -63,6 +104,13 @@ func TestDecodeURL(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 			got, err := decodeURL(tt.input)
+			if tt.wantErr {
+				var decodeErr *DecodeError
+				if !errors.As(err, &decodeErr) {
+					t.Fatalf("decodeURL = %q, %v, want a *DecodeError", got, err)
+				}
+				return
+			}
 			if err != nil {
 				t.Fatalf("decodeURL returned error: %v", err)
 			}
@@ -146,6 +194,49 @@ func TestParseStreamFormats(t *testing.T) {
 		if !strings.HasSuffix(f.MP4, ".mp4") {
 			t.Errorf("quality %q MP4 = %q, want .mp4 suffix", q, f.MP4)
 		}
+		if len(f.HLSURLs) < 2 || f.HLSURLs[0] != f.HLS {
+			t.Errorf("quality %q HLSURLs = %v, want every alternative starting with HLS", q, f.HLSURLs)
+		}
+		if len(f.MP4URLs) == 0 || f.MP4URLs[0] != f.MP4 {
+			t.Errorf("quality %q MP4URLs = %v, want every alternative starting with MP4", q, f.MP4URLs)
+		}
+		for _, u := range f.MP4URLs {
+			if !strings.HasSuffix(u, ".mp4") {
+				t.Errorf("quality %q MP4URLs contains %q, want only .mp4 URLs", q, u)
+			}
+		}
+		if len(slices.Compact(slices.Sorted(slices.Values(f.MP4URLs)))) != len(f.MP4URLs) {
+			t.Errorf("quality %q MP4URLs = %v, want no duplicates", q, f.MP4URLs)
+		}
+	}
+
+	// 1080p is served by two CDN hosts; the backup must be kept.
+	if got := len(formats["1080p"].MP4URLs); got != 2 {
+		t.Errorf("1080p MP4URLs = %v, want 2 alternatives", formats["1080p"].MP4URLs)
+	}
+}
+
+func TestParseStreamFormatsQuery(t *testing.T) {
+	t.Parallel()
+
+	formats := parseStreamFormats("[720p]https://a.cdn/v/720.mp4:hls:manifest.m3u8?t=1 or https://b.cdn/v/720.mp4?t=2 or https://c.cdn/v/720.mp4?t=3#frag or https://d.cdn/v/broken?t=4," +
+		"[1080p]https://a.cdn/v/1080.mp4:hls:manifest.m3u8?t=5")
+
+	got := formats["720p"]
+	wantMP4 := []string{"https://b.cdn/v/720.mp4?t=2", "https://c.cdn/v/720.mp4?t=3#frag"}
+	if !slices.Equal(got.MP4URLs, wantMP4) {
+		t.Errorf("720p MP4URLs = %v, want %v", got.MP4URLs, wantMP4)
+	}
+	wantHLS := []string{
+		"https://a.cdn/v/720.mp4:hls:manifest.m3u8?t=1",
+		"https://b.cdn/v/720.mp4:hls:manifest.m3u8?t=2",
+		"https://c.cdn/v/720.mp4:hls:manifest.m3u8?t=3#frag",
+	}
+	if !slices.Equal(got.HLSURLs, wantHLS) {
+		t.Errorf("720p HLSURLs = %v, want %v", got.HLSURLs, wantHLS)
+	}
+	if got := formats["1080p"].MP4URLs; !slices.Equal(got, []string{"https://a.cdn/v/1080.mp4?t=5"}) {
+		t.Errorf("1080p MP4URLs = %v, want the manifest URL without the suffix", got)
 	}
 }
 
@@ -159,3 +250,38 @@ func TestBoolTo10(t *testing.T) {
 		t.Errorf("boolTo10(false) = %q, want %q", got, "0")
 	}
 }
+
+func TestParseVideoMetadata(t *testing.T) {
+	t.Parallel()
+
+	if got, want := parseReleaseDate("12 апреля 2023 года"), time.Date(2023, time.April, 12, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("parseReleaseDate = %v, want %v", got, want)
+	}
+	if got := parseReleaseDate("2023 года"); !got.IsZero() {
+		t.Errorf("parseReleaseDate without day = %v, want zero time", got)
+	}
+
+	durations := map[string]time.Duration{
+		"98 мин.":      98 * time.Minute,
+		"1 ч. 38 мин.": 98 * time.Minute,
+		"":             0,
+	}
+	for in, want := range durations {
+		if got := parseRuntime(in); got != want {
+			t.Errorf("parseRuntime(%q) = %v, want %v", in, got, want)
+		}
+	}
+
+	if got := parseMinAge("16+ только для взрослых (Зрителям, достигшим 16 лет)"); got != 16 {
+		t.Errorf("parseMinAge = %d, want 16", got)
+	}
+
+	imdb := "/help/" + base64.StdEncoding.EncodeToString([]byte("https%3A%2F%2Fwww.imdb.com%2Ftitle%2Ftt4154756%2F")) + "/"
+	if got := reIMDbID.FindString(decodeHelpLink(imdb)); got != "tt4154756" {
+		t.Errorf("IMDb ID from %q = %q, want tt4154756", imdb, got)
+	}
+	kp := "/help/" + base64.StdEncoding.EncodeToString([]byte("https://www.kinopoisk.ru/film/843650/")) + "/"
+	if m := reKinopoiskID.FindStringSubmatch(decodeHelpLink(kp)); m == nil || m[1] != "843650" {
+		t.Errorf("Kinopoisk ID from %q = %v, want 843650", kp, m)
+	}
+}
@@ -0,0 +1,131 @@
+package hdrezka
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// reMovieVariant captures the is_camrip, is_ads and is_director arguments
+// the player is initialized with for the default movie stream.
+var reMovieVariant = regexp.MustCompile(`initCDNMoviesEvents\(\d+,\s*\d+,\s*(\d),\s*(\d),\s*(\d)`)
+
+// Variant is a version of a movie a translation is offered in. The zero
+// Variant is the regular theatrical cut.
+type Variant struct {
+	IsAds      bool `json:"is_ads,omitempty"`
+	IsCamRip   bool `json:"is_camrip,omitempty"`
+	IsDirector bool `json:"is_director,omitempty"`
+}
+
+// ParseVariant parses the names String returns: "theatrical", "director",
+// "camrip" and "ads", combined with "+".
+func ParseVariant(name string) (Variant, error) {
+	var v Variant
+	for _, part := range strings.Split(strings.ToLower(name), "+") {
+		switch strings.TrimSpace(part) {
+		case "theatrical":
+		case "director":
+			v.IsDirector = true
+		case "camrip":
+			v.IsCamRip = true
+		case "ads":
+			v.IsAds = true
+		default:
+			return Variant{}, fmt.Errorf("unknown version %q, use director, theatrical, camrip or ads", part)
+		}
+	}
+	return v, nil
+}
+
+func (v Variant) String() string {
+	var parts []string
+	if v.IsDirector {
+		parts = append(parts, "director")
+	}
+	if v.IsCamRip {
+		parts = append(parts, "camrip")
+	}
+	if v.IsAds {
+		parts = append(parts, "ads")
+	}
+	if len(parts) == 0 {
+		return "theatrical"
+	}
+	return strings.Join(parts, "+")
+}
+
+// Variant returns the version the translation streams.
+func (t *Translation) Variant() Variant {
+	return Variant{IsAds: t.IsAds, IsCamRip: t.IsCamRip, IsDirector: t.IsDirector}
+}
+
+// WithVariant returns a copy of the translation that streams version v,
+// e.g. tr.WithVariant(Variant{IsDirector: true}).GetStream().
+func (t *Translation) WithVariant(v Variant) *Translation {
+	variant := *t
+	variant.IsAds, variant.IsCamRip, variant.IsDirector = v.IsAds, v.IsCamRip, v.IsDirector
+	return &variant
+}
+
+// parseTranslations reads the translator list of a video page. html is the
+// page source holding the player initialization, which names the default
+// translator and, for movies, its version. Items of one translator that
+// differ only in version share the list of all of them in Variants.
+func parseTranslations(doc *goquery.Document, html string) []*Translation {
+	var defaultTranslator string
+	if m := reTranslate.FindStringSubmatch(html); m != nil {
+		defaultTranslator = m[2]
+	}
+	var defaultVariant *Variant
+	if m := reMovieVariant.FindStringSubmatch(html); m != nil {
+		defaultVariant = &Variant{IsCamRip: m[1] == "1", IsAds: m[2] == "1", IsDirector: m[3] == "1"}
+	}
+
+	var translations []*Translation
+	variants := make(map[string][]Variant)
+	doc.Find(".b-translator__item").Each(func(i int, s *goquery.Selection) {
+		name := strings.TrimSpace(s.Text())
+		translation := &Translation{
+			Name:       name,
+			ID:         s.AttrOr("data-translator_id", ""),
+			IsAds:      s.AttrOr("data-ads", "") == "1",
+			IsCamRip:   s.AttrOr("data-camrip", "") == "1",
+			IsDirector: s.AttrOr("data-director", "") == "1",
+			IsPremium:  s.HasClass("b-prem_translator"),
+			Language:   translationLanguage(name, s.Find("img[title]").AttrOr("title", "")),
+		}
+		if translation.ID == defaultTranslator {
+			translation.IsDefault = defaultVariant == nil || *defaultVariant == translation.Variant()
+		}
+		if v := translation.Variant(); !slices.Contains(variants[translation.ID], v) {
+			variants[translation.ID] = append(variants[translation.ID], v)
+		}
+		translations = append(translations, translation)
+	})
+	if len(translations) == 0 {
+		name := strings.TrimSpace(doc.Find("tr:contains('В переводе:')").Find("td").First().Next().Text())
+		translation := &Translation{
+			Name:      name,
+			ID:        defaultTranslator,
+			IsDefault: true,
+			Language:  translationLanguage(name, ""),
+		}
+		if defaultVariant != nil {
+			translation = translation.WithVariant(*defaultVariant)
+			variants[translation.ID] = []Variant{*defaultVariant}
+		}
+		translations = append(translations, translation)
+	}
+
+	// Only movies come in versions
+	if defaultVariant != nil {
+		for _, translation := range translations {
+			translation.Variants = variants[translation.ID]
+		}
+	}
+	return translations
+}
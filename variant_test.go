@@ -0,0 +1,80 @@
+package hdrezka
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const movieTranslatorsFixture = `<html><body>
+<ul id="translators-list" class="b-translators__list">
+	<li class="b-translator__item" data-translator_id="56" data-camrip="0" data-ads="0" data-director="0">Дубляж</li>
+	<li class="b-translator__item active" data-translator_id="56" data-camrip="0" data-ads="0" data-director="1">Дубляж</li>
+	<li class="b-translator__item" data-translator_id="111" data-camrip="1" data-ads="1" data-director="0">HDrezka Studio</li>
+</ul>
+<script>$(function () { sof.tv.initCDNMoviesEvents(12345, 56, 0, 0, 1, 'hdrezka.ag', false, {"id":"cdnplayer"}); });</script>
+</body></html>`
+
+func TestParseTranslations(t *testing.T) {
+	t.Parallel()
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(movieTranslatorsFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	html, _ := doc.Html()
+	translations := parseTranslations(doc, html)
+	if len(translations) != 3 {
+		t.Fatalf("parseTranslations() returned %d translations, want 3", len(translations))
+	}
+
+	theatrical, director := Variant{}, Variant{IsDirector: true}
+	tests := []struct {
+		variant   Variant
+		isDefault bool
+		variants  []Variant
+	}{
+		{theatrical, false, []Variant{theatrical, director}},
+		{director, true, []Variant{theatrical, director}},
+		{Variant{IsCamRip: true, IsAds: true}, false, []Variant{{IsCamRip: true, IsAds: true}}},
+	}
+	for i, tt := range tests {
+		tr := translations[i]
+		if tr.Variant() != tt.variant || tr.IsDefault != tt.isDefault || !slices.Equal(tr.Variants, tt.variants) {
+			t.Errorf("translation %d = %s default=%v variants=%v, want %s default=%v variants=%v",
+				i, tr.Variant(), tr.IsDefault, tr.Variants, tt.variant, tt.isDefault, tt.variants)
+		}
+	}
+
+	cut := translations[0].WithVariant(director)
+	if !cut.IsDirector || translations[0].IsDirector || cut.ID != "56" {
+		t.Errorf("WithVariant() = %+v, want a director copy leaving the original intact", cut)
+	}
+}
+
+func TestParseVariant(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		want    Variant
+		wantErr bool
+	}{
+		{"theatrical", Variant{}, false},
+		{"director", Variant{IsDirector: true}, false},
+		{"Director+camrip", Variant{IsDirector: true, IsCamRip: true}, false},
+		{"ads", Variant{IsAds: true}, false},
+		{"extended", Variant{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseVariant(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseVariant(%q) = %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+		if !tt.wantErr && !strings.EqualFold(got.String(), tt.name) {
+			t.Errorf("Variant.String() = %q, want %q", got.String(), tt.name)
+		}
+	}
+}
@@ -1,12 +1,14 @@
 package hdrezka
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 )
@@ -34,25 +36,42 @@ type Translation struct {
 	IsDefault  bool   `json:"is_default"`
 	IsDirector bool   `json:"is_director"`
 	IsPremium  bool   `json:"is_premium"`
+	// Language is the detected audio language of the translation.
+	Language Language `json:"language,omitempty"`
+	// Variants lists the movie versions offered for this translator; see
+	// WithVariant. It is empty for series.
+	Variants []Variant `json:"variants,omitempty"`
+	// episodes caches the episode list rendered into the video page.
+	episodes Episodes
 }
 
 // Video is a struct for video info
 type Video struct {
+	r               *HDRezka
 	Age             string         `json:"age,omitempty"`
 	Cast            []Person       `json:"cast,omitempty"`
 	Categories      []string       `json:"categories,omitempty"`
 	Country         []string       `json:"country,omitempty"`
 	Cover           string         `json:"cover,omitempty"`
+	DefaultEpisode  int            `json:"default_episode,omitempty"`
+	DefaultSeason   int            `json:"default_season,omitempty"`
 	DefaultStream   *Stream        `json:"default_stream,omitempty"`
 	Description     string         `json:"description,omitempty"`
 	Director        []Person       `json:"director,omitempty"`
 	Duration        string         `json:"duration,omitempty"`
 	ID              string         `json:"id"`
+	IMDbID          string         `json:"imdb_id,omitempty"`
+	KinopoiskID     string         `json:"kinopoisk_id,omitempty"`
+	MinAge          int            `json:"min_age,omitempty"`
 	Rating          Rating         `json:"rating,omitempty"`
 	RatingIMDB      Rating         `json:"rating_imdb,omitempty"`
 	RatingKinopoisk Rating         `json:"rating_kinopoisk,omitempty"`
 	ReleaseDate     string         `json:"release_date,omitempty"`
+	Released        time.Time      `json:"released,omitzero"`
+	ReleaseYear     int            `json:"release_year,omitempty"`
+	Runtime         time.Duration  `json:"runtime,omitempty"`
 	Quality         string         `json:"quality,omitempty"`
+	Schedule        []ScheduleItem `json:"schedule,omitempty"`
 	Tagline         string         `json:"tagline,omitempty"`
 	Title           string         `json:"title"`
 	TitleOriginal   string         `json:"title_original,omitempty"`
@@ -63,6 +82,11 @@ type Video struct {
 
 // GetVideo returns video info from URL.
 func (r *HDRezka) GetVideo(videoURL string) (*Video, error) {
+	return r.GetVideoContext(context.Background(), videoURL)
+}
+
+// GetVideoContext is GetVideo with a context that cancels the page request.
+func (r *HDRezka) GetVideoContext(ctx context.Context, videoURL string) (*Video, error) {
 	// Normalize video URL to use the base URL from this HDRezka instance
 	parsedURL, err := url.Parse(videoURL)
 	if err != nil {
@@ -74,7 +98,7 @@ func (r *HDRezka) GetVideo(videoURL string) (*Video, error) {
 	parsedURL.Host = r.URL.Host
 	normalizedURL := parsedURL.String()
 
-	doc, err := r.getDoc(normalizedURL)
+	doc, err := r.getDocContext(ctx, normalizedURL)
 	if err != nil {
 		return nil, err
 	}
@@ -89,7 +113,7 @@ func (r *HDRezka) GetVideo(videoURL string) (*Video, error) {
 		return nil, errors.New("sign in required")
 	}
 
-	video := &Video{}
+	video := &Video{r: r}
 
 	video.ID = doc.Find(".b-userset__fav_holder").AttrOr("data-post_id", "")
 	if video.ID == "" {
@@ -130,21 +154,25 @@ func (r *HDRezka) GetVideo(videoURL string) (*Video, error) {
 		Score: parseFloat(doc.Find("span.kp > span").Text()),
 		Votes: parseInt(doc.Find("span.kp > i").Text()),
 	}
+	video.IMDbID = reIMDbID.FindString(decodeHelpLink(doc.Find("span.imdb > a").AttrOr("href", "")))
+	if m := reKinopoiskID.FindStringSubmatch(decodeHelpLink(doc.Find("span.kp > a").AttrOr("href", ""))); m != nil {
+		video.KinopoiskID = m[1]
+	}
 	video.ReleaseDate = doc.Find("tr:contains('Дата выхода:')").Find("td").First().Next().Text()
 	video.Tagline = strings.Trim(doc.Find("tr:contains('Слоган:')").Find("td").First().Next().Text(), "«»")
 	video.Title = doc.Find("h1[itemprop=name]").Text()
 	video.TitleOriginal = doc.Find(".b-post__origtitle").Text()
+	video.Schedule = parseSchedule(doc)
 
 	// Get default stream
-	var defaultTranslator string
 	html, _ := doc.Html()
 	initCDNMatch := reTranslate.FindStringSubmatch(html)
 	if len(initCDNMatch) > 0 {
-		defaultTranslator = string(initCDNMatch[2])
 		var jsn struct {
 			Streams     string `json:"streams"`
 			Subtitle    any    `json:"subtitle"`
 			SubtitleDef any    `json:"subtitle_def"`
+			SubtitleLns any    `json:"subtitle_lns"`
 			Thumbnails  string `json:"thumbnails"`
 		}
 		err = json.NewDecoder(strings.NewReader(initCDNMatch[3])).Decode(&jsn)
@@ -153,6 +181,7 @@ func (r *HDRezka) GetVideo(videoURL string) (*Video, error) {
 		}
 		thumbnails := r.URL.JoinPath(jsn.Thumbnails)
 		video.DefaultStream = &Stream{
+			r:          r,
 			URL:        jsn.Streams,
 			Thumbnails: thumbnails.String(),
 		}
@@ -162,8 +191,10 @@ func (r *HDRezka) GetVideo(videoURL string) (*Video, error) {
 		if subtitleDefStr, ok := jsn.SubtitleDef.(string); ok && subtitleDefStr != "" {
 			video.DefaultStream.SubtitleDef = subtitleDefStr
 		}
+		video.DefaultStream.SubtitleLns = jsn.SubtitleLns
 		if video.DefaultStream.Subtitle != nil {
 			video.DefaultStream.Subtitles = parseSubtitles(video.DefaultStream.Subtitle.(string))
+			video.DefaultStream.SubtitleTracks = parseSubtitleTracks(video.DefaultStream.Subtitle.(string), jsn.SubtitleLns, jsn.SubtitleDef)
 		}
 		video.DefaultStream.URL, err = decodeURL(video.DefaultStream.URL)
 		if err != nil {
@@ -171,42 +202,43 @@ func (r *HDRezka) GetVideo(videoURL string) (*Video, error) {
 		} else {
 			video.DefaultStream.Formats = parseStreamFormats(video.DefaultStream.URL)
 		}
+		video.DefaultStream.FetchedAt = time.Now()
+		video.DefaultStream.ExpiresAt = streamExpiry(video.DefaultStream.Formats)
 	}
 
 	// Get translators
-	doc.Find(".b-translator__item").Each(func(i int, s *goquery.Selection) {
-		ua := s.Find("img[title=Украинский]").AttrOr("title", "")
-		if ua == "Украинский" {
-			ua = " UA"
+	video.Translation = parseTranslations(doc, html)
+	for _, translation := range video.Translation {
+		translation.r = r
+		translation.videoID = video.ID
+	}
+
+	// The player starts on the default translation and, for series, on
+	// the episode given to initCDNSeriesEvents
+	if m := reSeriesState.FindStringSubmatch(html); m != nil {
+		video.DefaultSeason, video.DefaultEpisode = parseInt(m[1]), parseInt(m[2])
+	}
+	episodes := parseEpisodes(doc.Selection)
+	for _, tr := range video.Translation {
+		if !tr.IsDefault {
+			continue
 		}
-		translation := &Translation{
-			r:          r,
-			videoID:    video.ID,
-			Name:       strings.TrimSpace(s.Text()) + ua,
-			ID:         s.AttrOr("data-translator_id", ""),
-			IsAds:      s.AttrOr("data-ads", "") == "1",
-			IsCamRip:   s.AttrOr("data-camrip", "") == "1",
-			IsDirector: s.AttrOr("data-director", "") == "1",
-			IsPremium:  s.HasClass("b-prem_translator"),
+		if len(episodes) > 0 {
+			tr.episodes = episodes
 		}
-		if translation.ID == defaultTranslator {
-			translation.IsDefault = true
+		if video.DefaultStream != nil && (initCDNMatch[1] == "Movies" || video.DefaultSeason > 0) {
+			video.DefaultStream.translation = tr
+			video.DefaultStream.season = video.DefaultSeason
+			video.DefaultStream.episode = video.DefaultEpisode
 		}
-		video.Translation = append(video.Translation, translation)
-	})
-	if len(video.Translation) == 0 {
-		name := doc.Find("tr:contains('В переводе:')").Find("td").First().Next().Text()
-		video.Translation = append(video.Translation, &Translation{
-			r:         r,
-			videoID:   video.ID,
-			Name:      strings.TrimSpace(name),
-			ID:        defaultTranslator,
-			IsDefault: true,
-		})
 	}
 
 	video.Type = Genre(strings.Split(videoURL, "/")[3])
 	video.Year = regexp.MustCompile(`\d{4}`).FindString(video.ReleaseDate)
+	video.ReleaseYear = parseInt(video.Year)
+	video.Released = parseReleaseDate(video.ReleaseDate)
+	video.Runtime = parseRuntime(video.Duration)
+	video.MinAge = parseMinAge(video.Age)
 
 	return video, nil
 }
@@ -281,6 +313,12 @@ func (video *Video) String() string {
 	for _, translation := range video.Translation {
 		name := translation.Name
 		if name != "" {
+			if translation.Language != "" && translation.Language != "ru" {
+				name += " (" + string(translation.Language) + ")"
+			}
+			if translation.Variant() != (Variant{}) {
+				name += " [" + translation.Variant().String() + "]"
+			}
 			if translation.IsDefault {
 				name += " [default]"
 			}
@@ -291,10 +329,14 @@ func (video *Video) String() string {
 		output += fmt.Sprintf("Translation:\t%s\n", strings.Join(translations, ", "))
 	}
 
-	if video.DefaultStream.Subtitles != nil {
+	if video.DefaultStream != nil && video.DefaultStream.SubtitleTracks != nil {
 		var subtitles []string
-		for lang := range video.DefaultStream.Subtitles {
-			subtitles = append(subtitles, lang)
+		for _, track := range video.DefaultStream.SubtitleTracks {
+			label := track.Label
+			if track.Language != "" {
+				label += " (" + string(track.Language) + ")"
+			}
+			subtitles = append(subtitles, label)
 		}
 		if len(subtitles) > 0 {
 			output += fmt.Sprintf("Subtitles:\t%s\n", strings.Join(subtitles, ", "))
@@ -0,0 +1,54 @@
+package watcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Handler receives the events of a Watcher. A returned error is reported by
+// Check but does not stop the other handlers.
+type Handler func(Event) error
+
+// JSONLines writes every event to w as one line of JSON.
+func JSONLines(w io.Writer) Handler {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(e Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return enc.Encode(e)
+	}
+}
+
+// webhookTimeout bounds a webhook request when no client is given, so a
+// hung endpoint cannot block Check.
+const webhookTimeout = 30 * time.Second
+
+// Webhook POSTs every event as JSON to url. A nil client uses one with a
+// 30 second timeout.
+func Webhook(url string, client *http.Client) Handler {
+	if client == nil {
+		client = &http.Client{Timeout: webhookTimeout}
+	}
+	return func(e Event) error {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("webhook %s: %s", url, resp.Status)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,323 @@
+// Package watcher polls HDrezka videos and reports new episodes, seasons,
+// translations and quality upgrades by diffing against a persisted snapshot.
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/n0madic/go-hdrezka"
+)
+
+// EventType is the kind of change an Event reports.
+type EventType string
+
+const (
+	NewEpisode      EventType = "new_episode"
+	NewSeason       EventType = "new_season"
+	NewTranslation  EventType = "new_translation"
+	QualityUpgraded EventType = "quality_upgraded"
+)
+
+// Event is a change found between two polls of a video.
+type Event struct {
+	Type    EventType `json:"type"`
+	Time    time.Time `json:"time"`
+	VideoID string    `json:"video_id"`
+	URL     string    `json:"url"`
+	Title   string    `json:"title"`
+	Cover   string    `json:"cover,omitempty"`
+	// Translation and TranslationID are set for every event but NewSeason.
+	Translation   string `json:"translation,omitempty"`
+	TranslationID string `json:"translation_id,omitempty"`
+	Season        int    `json:"season,omitempty"`
+	Episode       int    `json:"episode,omitempty"`
+	// Quality is the new best quality of a QualityUpgraded event and
+	// PreviousQuality the one it replaced.
+	Quality         string `json:"quality,omitempty"`
+	PreviousQuality string `json:"previous_quality,omitempty"`
+}
+
+// Snapshot is the state of the watched videos after a poll, keyed by URL.
+type Snapshot struct {
+	Videos map[string]*VideoState `json:"videos"`
+}
+
+// VideoState is what a poll saw of one video.
+type VideoState struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Cover     string    `json:"cover,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+	// Translations are keyed by translation ID.
+	Translations map[string]*TranslationState `json:"translations"`
+}
+
+// TranslationState is what a poll saw of one translation.
+type TranslationState struct {
+	Name string `json:"name"`
+	// Episodes lists the episodes of each season; empty for movies.
+	Episodes map[int][]int `json:"episodes,omitempty"`
+	// BestQuality is only tracked for the default translation, whose
+	// stream comes with the video page.
+	BestQuality string `json:"best_quality,omitempty"`
+}
+
+// LoadSnapshot reads a snapshot saved by Save. A missing file is an empty
+// snapshot, so the first poll records a baseline.
+func LoadSnapshot(path string) (*Snapshot, error) {
+	snapshot := &Snapshot{Videos: make(map[string]*VideoState)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return snapshot, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", path, err)
+	}
+	if snapshot.Videos == nil {
+		snapshot.Videos = make(map[string]*VideoState)
+	}
+	return snapshot, nil
+}
+
+// Save writes the snapshot to path, replacing it atomically.
+func (s *Snapshot) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Options configures a Watcher.
+type Options struct {
+	// Interval is the time between polls of Run; one hour when zero.
+	Interval time.Duration
+	// SnapshotPath is where the snapshot is loaded from and saved to after
+	// every poll. Without it the snapshot lives in memory only.
+	SnapshotPath string
+	// Jobs is the number of video pages fetched at once.
+	Jobs int
+}
+
+// Watcher polls a list of videos and passes the changes to its handlers.
+type Watcher struct {
+	r        *hdrezka.HDRezka
+	urls     []string
+	opts     Options
+	handlers []Handler
+	snapshot *Snapshot
+}
+
+// New creates a watcher for the videos at urls. r must be initialized.
+func New(r *hdrezka.HDRezka, urls []string, opts Options) *Watcher {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Hour
+	}
+	return &Watcher{r: r, urls: urls, opts: opts}
+}
+
+// Handle adds a handler that receives every event.
+func (w *Watcher) Handle(h Handler) *Watcher {
+	w.handlers = append(w.handlers, h)
+	return w
+}
+
+// Run polls until ctx is done, reporting poll errors to onError when set.
+func (w *Watcher) Run(ctx context.Context, onError func(error)) error {
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.Check(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check polls every video once, passes the events to the handlers, saves
+// the snapshot and returns the events. Videos seen for the first time only
+// record a baseline. A video that fails to load keeps its previous state;
+// its error is joined into the returned one.
+func (w *Watcher) Check(ctx context.Context) ([]Event, error) {
+	if w.snapshot == nil {
+		snapshot := &Snapshot{Videos: make(map[string]*VideoState)}
+		if w.opts.SnapshotPath != "" {
+			var err error
+			if snapshot, err = LoadSnapshot(w.opts.SnapshotPath); err != nil {
+				return nil, err
+			}
+		}
+		w.snapshot = snapshot
+	}
+
+	var (
+		events []Event
+		errs   []error
+	)
+	for _, result := range w.r.GetVideos(ctx, w.urls, hdrezka.BulkOption{Jobs: w.opts.Jobs}) {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.URL, result.Err))
+			continue
+		}
+		state, err := videoState(ctx, result.Video)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.URL, err))
+			continue
+		}
+		if prev, found := w.snapshot.Videos[result.URL]; found {
+			events = append(events, diff(result.URL, prev, state)...)
+		}
+		w.snapshot.Videos[result.URL] = state
+	}
+
+	for _, event := range events {
+		for _, h := range w.handlers {
+			if err := h(event); err != nil {
+				errs = append(errs, fmt.Errorf("handle %s event: %w", event.Type, err))
+			}
+		}
+	}
+	if w.opts.SnapshotPath != "" {
+		if err := w.snapshot.Save(w.opts.SnapshotPath); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return events, errors.Join(errs...)
+}
+
+// videoState collects the translations, episode lists and default quality
+// of video. A translation whose episode list fails to load fails the video,
+// so a temporary error is not mistaken for removed episodes.
+func videoState(ctx context.Context, video *hdrezka.Video) (*VideoState, error) {
+	series := video.Type == hdrezka.Series || video.DefaultSeason > 0
+	state := &VideoState{
+		ID:           video.ID,
+		Title:        video.Title,
+		Cover:        video.Cover,
+		CheckedAt:    time.Now(),
+		Translations: make(map[string]*TranslationState),
+	}
+	for _, tr := range video.Translation {
+		ts := &TranslationState{Name: tr.Name}
+		if series {
+			episodes, err := tr.GetEpisodesContext(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("episodes of %s: %w", tr.Name, err)
+			}
+			ts.Episodes = make(map[int][]int)
+			for _, season := range episodes.ListSeasons() {
+				ts.Episodes[season] = episodes.ListEpisodes(season)
+			}
+		}
+		if tr.IsDefault && video.DefaultStream != nil {
+			if qualities := video.DefaultStream.Qualities(); len(qualities) > 0 {
+				ts.BestQuality = qualities[len(qualities)-1].Label
+			}
+		}
+		state.Translations[tr.ID] = ts
+	}
+	return state, nil
+}
+
+// diff returns the events between two states of the video at url.
+func diff(url string, prev, cur *VideoState) []Event {
+	now := time.Now()
+	event := func(t EventType, trID string) Event {
+		e := Event{Type: t, Time: now, VideoID: cur.ID, URL: url, Title: cur.Title, Cover: cur.Cover}
+		if trID != "" {
+			e.TranslationID, e.Translation = trID, cur.Translations[trID].Name
+		}
+		return e
+	}
+
+	var events []Event
+	knownSeasons := make(map[int]bool)
+	for _, ts := range prev.Translations {
+		for season := range ts.Episodes {
+			knownSeasons[season] = true
+		}
+	}
+
+	ids := make([]string, 0, len(cur.Translations))
+	for id := range cur.Translations {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	newSeasons := make(map[int]bool)
+	for _, id := range ids {
+		ts := cur.Translations[id]
+		for season := range ts.Episodes {
+			if !knownSeasons[season] {
+				newSeasons[season] = true
+			}
+		}
+
+		old, found := prev.Translations[id]
+		if !found {
+			events = append(events, event(NewTranslation, id))
+			continue
+		}
+
+		seasons := make([]int, 0, len(ts.Episodes))
+		for season := range ts.Episodes {
+			seasons = append(seasons, season)
+		}
+		slices.Sort(seasons)
+		for _, season := range seasons {
+			for _, episode := range ts.Episodes[season] {
+				if !slices.Contains(old.Episodes[season], episode) {
+					e := event(NewEpisode, id)
+					e.Season, e.Episode = season, episode
+					events = append(events, e)
+				}
+			}
+		}
+
+		if old.BestQuality != "" && ts.BestQuality != "" &&
+			hdrezka.ParseQuality(ts.BestQuality).Compare(hdrezka.ParseQuality(old.BestQuality)) > 0 {
+			e := event(QualityUpgraded, id)
+			e.Quality, e.PreviousQuality = ts.BestQuality, old.BestQuality
+			events = append(events, e)
+		}
+	}
+
+	seasons := make([]int, 0, len(newSeasons))
+	for season := range newSeasons {
+		seasons = append(seasons, season)
+	}
+	slices.Sort(seasons)
+	for _, season := range seasons {
+		e := event(NewSeason, "")
+		e.Season = season
+		events = append(events, e)
+	}
+	return events
+}
@@ -0,0 +1,191 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/n0madic/go-hdrezka"
+)
+
+const pageFixture = `<html><head><title>Series</title></head><body>
+<h1 itemprop="name">Сериал</h1>
+<div class="b-userset__fav_holder" data-post_id="123"></div>
+<ul id="translators-list" class="b-translators__list">
+	<li class="b-translator__item active" data-translator_id="56">Дубляж</li>
+	<li class="b-translator__item" data-translator_id="111">HDrezka Studio</li>
+	%s
+</ul>
+<div id="simple-episodes-tabs">
+	<ul id="simple-episodes-list-1" class="b-simple_episodes__list">
+		<li class="b-simple_episode__item active" data-id="123" data-season_id="1" data-episode_id="1">Серия 1</li>
+		%s
+	</ul>
+</div>
+<script>$(function () { sof.tv.initCDNSeriesEvents(123, 56, 1, 1, false, 'hdrezka.ag', false, {"id":"cdnplayer","streams":"%s","thumbnails":""}); });</script>
+</body></html>`
+
+func episodeList(season, episodes int) string {
+	var b strings.Builder
+	b.WriteString(`<ul class="b-simple_episodes__list">`)
+	for e := 1; e <= episodes; e++ {
+		fmt.Fprintf(&b, `<li class="b-simple_episode__item" data-id="123" data-season_id="%d" data-episode_id="%d">%d</li>`, season, e, e)
+	}
+	b.WriteString(`</ul>`)
+	return b.String()
+}
+
+func TestWatcherCheck(t *testing.T) {
+	t.Parallel()
+
+	var poll atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		second := poll.Load() > 0
+		switch req.URL.Path {
+		case "/series/drama/123-serial-2020.html":
+			if !second {
+				fmt.Fprintf(w, pageFixture, "", "", "[720p]https://cdn.example.com/a.mp4")
+				return
+			}
+			fmt.Fprintf(w, pageFixture,
+				`<li class="b-translator__item" data-translator_id="222">Кубик в Кубе</li>`,
+				`<li class="b-simple_episode__item" data-id="123" data-season_id="1" data-episode_id="2">Серия 2</li>`,
+				"[720p]https://cdn.example.com/a.mp4,[1080p]https://cdn.example.com/b.mp4")
+		case "/ajax/get_cdn_series/":
+			episodes := episodeList(1, 1)
+			if second {
+				episodes += episodeList(2, 1)
+			}
+			json.NewEncoder(w).Encode(map[string]any{"success": true, "episodes": episodes})
+		case "/films/drama/404-missing.html":
+			http.NotFound(w, req)
+		default:
+			t.Errorf("unexpected request %s", req.URL)
+			http.NotFound(w, req)
+		}
+	}))
+	defer srv.Close()
+
+	r := hdrezka.New()
+	r.URL, _ = url.Parse(srv.URL)
+	videoURL := srv.URL + "/series/drama/123-serial-2020.html"
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+
+	var handled []Event
+	w := New(r, []string{videoURL, srv.URL + "/films/drama/404-missing.html"}, Options{SnapshotPath: snapshotPath}).
+		Handle(func(e Event) error {
+			handled = append(handled, e)
+			return nil
+		})
+
+	events, err := w.Check(context.Background())
+	if len(events) != 0 {
+		t.Errorf("first Check() events = %+v, want a silent baseline", events)
+	}
+	if err == nil || !strings.Contains(err.Error(), "404-missing") {
+		t.Errorf("first Check() error = %v, want the missing video error", err)
+	}
+
+	// A new watcher must pick up the saved baseline
+	poll.Add(1)
+	w = New(r, []string{videoURL}, Options{SnapshotPath: snapshotPath}).
+		Handle(func(e Event) error {
+			handled = append(handled, e)
+			return nil
+		})
+	events, err = w.Check(context.Background())
+	if err != nil {
+		t.Fatalf("second Check() error = %v", err)
+	}
+
+	type key struct {
+		Type          EventType
+		TranslationID string
+		Season        int
+		Episode       int
+		Quality       string
+	}
+	var got []key
+	for _, e := range events {
+		if e.VideoID != "123" || e.URL != videoURL {
+			t.Errorf("event %+v has wrong video", e)
+		}
+		got = append(got, key{e.Type, e.TranslationID, e.Season, e.Episode, e.Quality})
+	}
+	want := []key{
+		{Type: NewEpisode, TranslationID: "111", Season: 2, Episode: 1},
+		{Type: NewTranslation, TranslationID: "222"},
+		{Type: NewEpisode, TranslationID: "56", Season: 1, Episode: 2},
+		{Type: QualityUpgraded, TranslationID: "56", Quality: "1080p"},
+		{Type: NewSeason, Season: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("second Check() events = %+v, want %+v", got, want)
+	}
+	if !reflect.DeepEqual(handled, events) {
+		t.Errorf("handler got %d events, want %d", len(handled), len(events))
+	}
+
+	snapshot, err := LoadSnapshot(snapshotPath)
+	if err != nil {
+		t.Fatalf("LoadSnapshot() error = %v", err)
+	}
+	if got := snapshot.Videos[videoURL].Translations["56"].BestQuality; got != "1080p" {
+		t.Errorf("snapshot best quality = %q, want 1080p", got)
+	}
+
+	if events, _ := w.Check(context.Background()); len(events) != 0 {
+		t.Errorf("unchanged Check() events = %+v, want none", events)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	t.Parallel()
+
+	event := Event{Type: NewEpisode, VideoID: "123", Season: 1, Episode: 2}
+
+	var buf bytes.Buffer
+	if err := JSONLines(&buf)(event); err != nil {
+		t.Fatalf("JSONLines() error = %v", err)
+	}
+	if got := buf.String(); !strings.HasSuffix(got, "\n") || !strings.Contains(got, `"type":"new_episode"`) {
+		t.Errorf("JSONLines() wrote %q", got)
+	}
+
+	var (
+		mu   sync.Mutex
+		body []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/fail" {
+			http.Error(w, "nope", http.StatusInternalServerError)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ = io.ReadAll(req.Body)
+	}))
+	defer srv.Close()
+
+	if err := Webhook(srv.URL+"/hook", nil)(event); err != nil {
+		t.Fatalf("Webhook() error = %v", err)
+	}
+	var got Event
+	if err := json.Unmarshal(body, &got); err != nil || !reflect.DeepEqual(got, event) {
+		t.Errorf("Webhook() posted %s, want %+v", body, event)
+	}
+	if err := Webhook(srv.URL+"/fail", nil)(event); err == nil {
+		t.Error("Webhook() error = nil, want the failed status")
+	}
+}